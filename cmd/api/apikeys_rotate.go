@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rotateID        string
+	rotateName      string
+	rotateExpiresAt string
+	rotateJSON      bool
+)
+
+var rotatedSuffixRe = regexp.MustCompile(`\s*\(rotated \d{4}-\d{2}-\d{2}\)$`)
+
+var apikeysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the API key in use with a new one",
+	Long: "Creates a new API key named and timed after the one in use, checks it against /api/validate, writes it " +
+		"where the current key is configured (the active profile in profiles.yaml, or api_token in labrador.yaml) " +
+		"and revokes the old key. If a step fails, the steps before it are undone: the new key is revoked and the " +
+		"old one is written back.\n\nThe old key is found in the key list by its prefix or last characters; pass " +
+		"--id when that is not possible. The new key keeps the old key's lifetime unless --expires-at is set.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts, err := resolveAPIConfig()
+		if err != nil {
+			return err
+		}
+		if opts.APIKey == "" {
+			return fmt.Errorf("apikeys rotate needs an API key (api_token), not a bearer token")
+		}
+		loc, err := locateAPIKey(cmd)
+		if err != nil {
+			return err
+		}
+		old, err := findCurrentAPIKey(cmd, opts, rotateID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		name := rotateName
+		if name == "" {
+			base := strings.TrimSpace(rotatedSuffixRe.ReplaceAllString(old.Name, ""))
+			if base == "" {
+				base = "labradoc-cli"
+			}
+			name = fmt.Sprintf("%s (rotated %s)", base, now.Format(time.DateOnly))
+		}
+		expiresAt := rotateExpiresAt
+		if expiresAt == "" {
+			created, expires := parseAPITime(old.CreatedAt), parseAPITime(old.ExpiresAt)
+			if !created.IsZero() && expires.After(created) {
+				expiresAt = now.Add(expires.Sub(created)).Format(time.RFC3339)
+			}
+		}
+
+		say := func(format string, args ...any) {
+			if !rotateJSON {
+				fmt.Fprintf(os.Stdout, format+"\n", args...)
+			}
+		}
+
+		created, err := createAPIKey(cmd, opts, name, expiresAt)
+		if err != nil {
+			return fmt.Errorf("create new API key: %w", err)
+		}
+		newOpts := opts
+		newOpts.APIKey = created.Key
+		say("Created API key %q (%s).", name, created.ID)
+
+		// rollback undoes the completed steps and reports what could not be
+		// undone alongside the original failure.
+		rollback := func(step string, cause error, restoreConfig bool) error {
+			errs := []error{fmt.Errorf("%s: %w", step, cause)}
+			if restoreConfig {
+				if err := loc.write(loc.original); err != nil {
+					errs = append(errs, fmt.Errorf("rollback: restore old key in %s: %w", loc, err))
+				}
+			}
+			if err := apiKeyRequest(cmd, opts, http.MethodDelete, "/api/user/apikeys/"+created.ID, nil, nil); err != nil {
+				errs = append(errs, fmt.Errorf("rollback: revoke new key %s: %w", created.ID, err))
+			}
+			if len(errs) == 1 {
+				errs = append(errs, errors.New("rolled back; the old key is still in use"))
+			}
+			return errors.Join(errs...)
+		}
+
+		if err := apiKeyRequest(cmd, newOpts, http.MethodGet, "/api/validate", nil, nil); err != nil {
+			return rollback("verify new API key", err, false)
+		}
+		say("Verified the new key against /api/validate.")
+
+		stored := created.Key
+		if loc.sealed {
+			if stored, err = cli.SealSecret(created.Key); err != nil {
+				return rollback("encrypt new API key", err, false)
+			}
+		}
+		if err := loc.write(stored); err != nil {
+			return rollback("write new API key to "+loc.String(), err, false)
+		}
+		say("Updated %s.", loc)
+
+		var revokeWarning string
+		if err := apiKeyRequest(cmd, newOpts, http.MethodDelete, "/api/user/apikeys/"+old.ID, nil, nil); err != nil {
+			// The request may have failed after the server revoked the key.
+			// Rolling back then would leave no working key, so only do it
+			// when the old key still validates.
+			verr := apiKeyRequest(cmd, opts, http.MethodGet, "/api/validate", nil, nil)
+			var httpErr *cli.HTTPError
+			switch {
+			case verr == nil:
+				return rollback("revoke old API key "+old.ID, err, true)
+			case errors.As(verr, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden):
+				revokeWarning = fmt.Sprintf("revoking old API key %s reported an error (%v), but the old key no longer validates", old.ID, err)
+				if !rotateJSON {
+					fmt.Fprintf(os.Stderr, "warning: %s; keeping the new key.\n", revokeWarning)
+				}
+			default:
+				return fmt.Errorf("revoke old API key %s: %w; could not check whether it was revoked (%v). The new key is in use; "+
+					"check apikeys list and revoke %s if it is still there", old.ID, err, verr, old.ID)
+			}
+		} else {
+			say("Revoked old API key %s.", old.ID)
+		}
+
+		if rotateJSON {
+			out := map[string]any{
+				"status":     "ok",
+				"old_id":     old.ID,
+				"new_id":     created.ID,
+				"name":       name,
+				"expires_at": expiresAt,
+				"updated":    loc.String(),
+			}
+			if revokeWarning != "" {
+				out["warning"] = revokeWarning
+			}
+			b, _ := json.Marshal(out)
+			fmt.Fprintln(os.Stdout, string(b))
+		}
+		return nil
+	},
+}
+
+// apiKeyLocation is where the API key in use is configured.
+type apiKeyLocation struct {
+	profile  string // entry in profiles.yaml, or
+	path     string // labrador*.yaml file
+	original string // stored value, possibly sealed
+	sealed   bool
+}
+
+func (l apiKeyLocation) String() string {
+	if l.profile != "" {
+		return fmt.Sprintf("profile %q", l.profile)
+	}
+	return l.path
+}
+
+func (l apiKeyLocation) write(value string) error {
+	if l.profile == "" {
+		return cli.SetConfigFileValue(l.path, "api_token", value)
+	}
+	profiles, err := cli.LoadProfiles()
+	if err != nil {
+		return err
+	}
+	p, ok := profiles.Profiles[l.profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found", l.profile)
+	}
+	p.APIToken = value
+	return cli.SaveProfiles(profiles)
+}
+
+// locateAPIKey finds where the API key in use comes from, following the same
+// precedence as resolveAPIConfig. Keys given by flag or environment cannot be
+// updated and are refused before anything is created.
+func locateAPIKey(cmd *cobra.Command) (apiKeyLocation, error) {
+	if cmd.Flags().Changed("api-token") {
+		return apiKeyLocation{}, fmt.Errorf("the API key comes from --api-token; rotate the key where it is stored")
+	}
+	if _, ok := os.LookupEnv("API_TOKEN"); ok {
+		return apiKeyLocation{}, fmt.Errorf("the API key comes from API_TOKEN; rotate the key where it is stored")
+	}
+	if profile := cli.ActiveProfile(); profile != cli.DefaultProfile {
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return apiKeyLocation{}, err
+		}
+		if p, ok := profiles.Profiles[profile]; ok && p.APIToken != "" {
+			return apiKeyLocation{profile: profile, original: p.APIToken, sealed: cli.IsSealedSecret(p.APIToken)}, nil
+		}
+	}
+	env := os.Getenv("ENVIRONMENT")
+	if env == "" {
+		env = "prod"
+	}
+	// labrador.<env>.yaml is merged over labrador.yaml, so it wins.
+	for _, path := range []string{"labrador." + env + ".yaml", "labrador.yaml"} {
+		value, found, err := cli.ConfigFileValue(path, "api_token")
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return apiKeyLocation{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if found && value != "" {
+			return apiKeyLocation{path: path, original: value, sealed: cli.IsSealedSecret(value)}, nil
+		}
+	}
+	return apiKeyLocation{}, fmt.Errorf("the API key is not stored in a profile or labrador.yaml; nothing to update")
+}
+
+// findCurrentAPIKey returns the listed key with the given ID, or the one
+// matching the key in use.
+func findCurrentAPIKey(cmd *cobra.Command, opts cli.RequestOptions, id string) (apiKey, error) {
+	var b []byte
+	var keys []apiKey
+	if err := apiKeyRequest(cmd, opts, http.MethodGet, "/api/user/apikeys", nil, &b); err != nil {
+		return apiKey{}, fmt.Errorf("list API keys: %w", err)
+	}
+	if err := decodeItems(b, &keys); err != nil {
+		return apiKey{}, fmt.Errorf("list API keys: %w", err)
+	}
+	var matches []apiKey
+	for _, k := range keys {
+		if (id != "" && k.ID == id) || (id == "" && k.Matches(opts.APIKey)) {
+			matches = append(matches, k)
+		}
+	}
+	switch {
+	case len(matches) == 1 && matches[0].ID != "":
+		return matches[0], nil
+	case id != "":
+		return apiKey{}, fmt.Errorf("API key %s not found", id)
+	case len(matches) == 0:
+		return apiKey{}, fmt.Errorf("cannot tell which API key is in use; pass --id (see apikeys list)")
+	}
+	return apiKey{}, fmt.Errorf("%d API keys match the key in use; pass --id (see apikeys list)", len(matches))
+}
+
+func createAPIKey(cmd *cobra.Command, opts cli.RequestOptions, name, expiresAt string) (apiKey, error) {
+	payload := map[string]string{"name": name}
+	if expiresAt != "" {
+		payload["expiresAt"] = expiresAt
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return apiKey{}, err
+	}
+	var b []byte
+	if err := apiKeyRequest(cmd, opts, http.MethodPost, "/api/user/apikeys", body, &b); err != nil {
+		return apiKey{}, err
+	}
+	var k apiKey
+	if err := json.Unmarshal(b, &k); err != nil {
+		return apiKey{}, err
+	}
+	if k.ID == "" {
+		return apiKey{}, fmt.Errorf("response has no key id: %s", strings.TrimSpace(string(b)))
+	}
+	if k.Key == "" {
+		err := fmt.Errorf("response has no key value")
+		if rerr := apiKeyRequest(cmd, opts, http.MethodDelete, "/api/user/apikeys/"+k.ID, nil, nil); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("revoke key %s: %w", k.ID, rerr))
+		}
+		return apiKey{}, err
+	}
+	return k, nil
+}
+
+// apiKeyRequest sends one request and stores the response body in out when
+// it is not nil. HTTP error statuses are returned as *cli.HTTPError.
+func apiKeyRequest(cmd *cobra.Command, opts cli.RequestOptions, method, path string, body []byte, out *[]byte) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+		opts.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+	resp, err := cli.DoRequest(cmd.Context(), method, path, r, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return &cli.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(b))}
+	}
+	if out != nil {
+		*out = b
+	}
+	return nil
+}
+
+func init() {
+	apikeysCmd.AddCommand(apikeysRotateCmd)
+
+	apikeysRotateCmd.Flags().StringVar(&rotateID, "id", "", "ID of the API key in use (found automatically when the key list shows prefixes)")
+	apikeysRotateCmd.Flags().StringVar(&rotateName, "name", "", "Name of the new key (default: old name with a rotation date)")
+	apikeysRotateCmd.Flags().StringVar(&rotateExpiresAt, "expires-at", "", "Expiry of the new key, RFC 3339 (default: the old key's lifetime from now)")
+	apikeysRotateCmd.Flags().BoolVar(&rotateJSON, "json", false, "Output machine-readable JSON")
+}
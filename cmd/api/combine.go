@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+)
+
+var (
+	uploadCombine    bool
+	uploadName       string
+	uploadAutoRotate bool
+	uploadDPI        int
+	uploadPageSize   string
+)
+
+// combineImages builds one PDF from the image paths, in argument order, and
+// returns it with the name it should be uploaded under.
+func combineImages(paths []string) (string, []byte, error) {
+	if len(paths) == 0 {
+		return "", nil, fmt.Errorf("missing images to combine")
+	}
+	sources := make([]cli.ImageSource, 0, len(paths))
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", nil, err
+		}
+		sources = append(sources, cli.ImageSource{Name: p, Data: b})
+	}
+	pdf, err := cli.BuildImagePDF(sources, cli.ImagePDFOptions{
+		PageSize:   uploadPageSize,
+		DPI:        uploadDPI,
+		AutoRotate: uploadAutoRotate,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	name := uploadName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(paths[0]), filepath.Ext(paths[0]))
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+	return name, pdf, nil
+}
+
+func init() {
+	filesUploadCmd.Flags().BoolVar(&uploadCombine, "combine", false, "Combine the image arguments into one PDF (one page per image, in order) and upload it")
+	filesUploadCmd.Flags().StringVar(&uploadName, "name", "", "File name for the combined PDF (default: first image name)")
+	filesUploadCmd.Flags().BoolVar(&uploadAutoRotate, "auto-rotate", true, "Rotate JPEGs upright using their EXIF orientation when combining")
+	filesUploadCmd.Flags().IntVar(&uploadDPI, "dpi", 0, "Downscale combined images to at most this DPI on the page (0 keeps full resolution)")
+	filesUploadCmd.Flags().StringVar(&uploadPageSize, "page-size", cli.PageSizeA4, "Page size for combined images: a4, letter, image")
+}
@@ -4,6 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
 
 	"github.com/spf13/cobra"
 )
@@ -13,14 +24,6 @@ var emailCmd = &cobra.Command{
 	Short: "Email operations via the API",
 }
 
-var emailAddressesCmd = &cobra.Command{
-	Use:   "addresses",
-	Short: "List email addresses",
-	RunE: func(cmd *cobra.Command, _ []string) error {
-		return simpleGet(cmd, "/api/emailAddresses", "")
-	},
-}
-
 var (
 	emailDescription string
 )
@@ -37,18 +40,239 @@ var emailRequestCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return simplePost(cmd, "/api/emailAddress", bytes.NewReader(body), "application/json", "")
+		if !emailCopy {
+			return simplePost(cmd, "/api/emailAddress", bytes.NewReader(body), "application/json", "")
+		}
+		opts, err := resolveAPIConfig()
+		if err != nil {
+			return err
+		}
+		if opts.APIKey == "" && opts.Token == "" {
+			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+		}
+		opts.Headers = map[string]string{"Content-Type": "application/json"}
+		resp, err := cli.DoRequest(cmd.Context(), "POST", "/api/emailAddress", bytes.NewReader(body), opts)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, strings.TrimSpace(string(b)))
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("request failed: %s", resp.Status)
+		}
+		var addr apiEmailAddress
+		if err := json.Unmarshal(b, &addr); err != nil || addr.Address == "" {
+			return fmt.Errorf("--copy: no address in response")
+		}
+		return copyAddress(addr.Address)
 	},
 }
 
+var (
+	emailAddress    string
+	emailSince      string
+	emailOutput     string
+	emailPageSize   int
+	emailPageNumber int
+	emailWatch      bool
+	emailInterval   time.Duration
+)
+
 var emailListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List emails",
+	Long: "Lists emails from /api/emails. Without filters, --watch or --output table the response is printed unchanged; " +
+		"otherwise pages are followed until all are fetched unless --page-number is given. --output table shows sender, receiving address, subject and the body part indices for email body. " +
+		"--watch keeps polling and prints only newly received emails.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		return simpleGet(cmd, "/api/emails", "")
+		if emailOutput != "json" && emailOutput != "table" {
+			return fmt.Errorf("invalid --output %q; valid values: json, table", emailOutput)
+		}
+		if emailWatch && emailInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		since, err := parseSince(emailSince)
+		if err != nil {
+			return err
+		}
+		if emailOutput == "json" && emailAddress == "" && since.IsZero() && !emailWatch {
+			query := url.Values{}
+			if emailPageNumber > 0 {
+				query.Set("pageNumber", strconv.Itoa(emailPageNumber))
+			}
+			if emailPageNumber > 0 || cmd.Flags().Changed("page-size") {
+				query.Set("pageSize", strconv.Itoa(emailPageSize))
+			}
+			path := "/api/emails"
+			if len(query) > 0 {
+				path += "?" + query.Encode()
+			}
+			return simpleGet(cmd, path, "")
+		}
+		match := func(e apiEmail) bool {
+			if emailAddress != "" && !strings.EqualFold(e.To, emailAddress) {
+				return false
+			}
+			if !since.IsZero() && !e.Received().After(since) {
+				return false
+			}
+			return true
+		}
+		fetch := func() ([]apiEmail, error) {
+			emails, err := fetchEmails(cmd)
+			if err != nil {
+				return nil, err
+			}
+			out := emails[:0:0]
+			for _, e := range emails {
+				if match(e) {
+					out = append(out, e)
+				}
+			}
+			sortEmails(out)
+			return out, nil
+		}
+
+		emails, err := fetch()
+		if err != nil {
+			return err
+		}
+		if !emailWatch {
+			if emailOutput == "table" {
+				return writeEmailTable(os.Stdout, emails, true)
+			}
+			raw := make([]map[string]any, len(emails))
+			for i, e := range emails {
+				raw[i] = e.Raw
+			}
+			b, err := json.Marshal(raw)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, string(b))
+			return nil
+		}
+
+		// Watch: remember what is already there and print only new emails,
+		// one JSON object per line or one table row each.
+		seen := map[string]struct{}{}
+		for _, e := range emails {
+			seen[e.ID] = struct{}{}
+		}
+		if emailOutput == "table" {
+			if err := writeEmailTable(os.Stdout, nil, true); err != nil {
+				return err
+			}
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		cmd.SetContext(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(emailInterval):
+			}
+			emails, err := fetch()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+				continue
+			}
+			for _, e := range emails {
+				if _, ok := seen[e.ID]; ok {
+					continue
+				}
+				seen[e.ID] = struct{}{}
+				if emailOutput == "table" {
+					err = writeEmailTable(os.Stdout, []apiEmail{e}, false)
+				} else {
+					var b []byte
+					if b, err = json.Marshal(e.Raw); err == nil {
+						_, err = fmt.Fprintln(os.Stdout, string(b))
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
 	},
 }
 
+func fetchEmails(cmd *cobra.Command) ([]apiEmail, error) {
+	if emailPageNumber > 0 {
+		query := url.Values{}
+		query.Set("pageNumber", strconv.Itoa(emailPageNumber))
+		if emailPageSize > 0 {
+			query.Set("pageSize", strconv.Itoa(emailPageSize))
+		}
+		b, err := getBytes(cmd, "/api/emails?"+query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var emails []apiEmail
+		return emails, decodeItems(b, &emails)
+	}
+	return listAllPages(cmd, "/api/emails", nil, emailPageSize, func(e apiEmail) string { return e.ID })
+}
+
+// sortEmails orders emails oldest first.
+func sortEmails(emails []apiEmail) {
+	sort.SliceStable(emails, func(i, j int) bool { return emails[i].Received().Before(emails[j].Received()) })
+}
+
+// parseSince accepts a date, an RFC 3339 timestamp, a Go duration such as 36h
+// or a number of days such as 7d, the last two counting back from now.
+func parseSince(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t := parseAPITime(s); !t.IsZero() {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use YYYY-MM-DD, RFC 3339, a duration like 36h, or 7d)", s)
+}
+
+func writeEmailTable(w io.Writer, emails []apiEmail, header bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if header {
+		fmt.Fprintln(tw, "ID\tRECEIVED\tFROM\tTO\tSUBJECT\tPARTS")
+	}
+	for _, e := range emails {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, formatLocal(e.Received()), truncate(e.From, 40), e.To, truncate(e.Subject, 50), emailPartsSummary(e.Parts))
+	}
+	return tw.Flush()
+}
+
+// emailPartsSummary renders parts as "1 text/html, 2 invoice.pdf".
+func emailPartsSummary(parts []apiEmailPart) string {
+	if len(parts) == 0 {
+		return "-"
+	}
+	out := make([]string, len(parts))
+	for i, p := range parts {
+		label := p.Name
+		if label == "" {
+			label = p.ContentType
+		}
+		out[i] = strings.TrimSpace(fmt.Sprintf("%d %s", p.Index, label))
+	}
+	return strings.Join(out, ", ")
+}
+
 var (
 	emailID    string
 	emailIndex int
@@ -58,6 +282,7 @@ var (
 var emailBodyCmd = &cobra.Command{
 	Use:   "body",
 	Short: "Get email body",
+	Long:  "Downloads one body part of an email. email list --output table shows the part indices.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		if emailID == "" {
 			return fmt.Errorf("missing --id")
@@ -71,12 +296,19 @@ var emailBodyCmd = &cobra.Command{
 }
 
 func init() {
-	emailCmd.AddCommand(emailAddressesCmd)
 	emailCmd.AddCommand(emailRequestCmd)
 	emailCmd.AddCommand(emailListCmd)
 	emailCmd.AddCommand(emailBodyCmd)
 
 	emailRequestCmd.Flags().StringVar(&emailDescription, "description", "", "Optional description for the email address")
+	emailRequestCmd.Flags().BoolVar(&emailCopy, "copy", false, "Copy the new address to the clipboard (OSC 52)")
+	emailListCmd.Flags().StringVar(&emailAddress, "address", "", "Only emails received by this Labradoc address")
+	emailListCmd.Flags().StringVar(&emailSince, "since", "", "Only emails received after this date, timestamp or age (e.g. 2026-05-01, 36h, 7d)")
+	emailListCmd.Flags().StringVar(&emailOutput, "output", "json", "Output format: json, table")
+	emailListCmd.Flags().IntVar(&emailPageSize, "page-size", 100, "Emails per request")
+	emailListCmd.Flags().IntVar(&emailPageNumber, "page-number", 0, "Fetch only this page instead of all pages")
+	emailListCmd.Flags().BoolVar(&emailWatch, "watch", false, "Keep polling and print newly received emails")
+	emailListCmd.Flags().DurationVar(&emailInterval, "interval", time.Minute, "Polling interval for --watch")
 	emailBodyCmd.Flags().StringVar(&emailID, "id", "", "Email ID")
 	emailBodyCmd.Flags().IntVar(&emailIndex, "index", 0, "Email index")
 	emailBodyCmd.Flags().StringVar(&emailOut, "out", "", "Write response to file instead of stdout")
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	emailAddressesOutput string
+	emailShowOutput      string
+	emailCopy            bool
+	emailRecent          int
+)
+
+var emailAddressesCmd = &cobra.Command{
+	Use:   "addresses",
+	Short: "List email addresses",
+	Long: "Lists inbound addresses from /api/emailAddresses. --output table adds the description, creation date and " +
+		"how many emails and files each address has produced, counted from /api/emails.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if emailAddressesOutput != "json" && emailAddressesOutput != "table" {
+			return fmt.Errorf("invalid --output %q; valid values: json, table", emailAddressesOutput)
+		}
+		if emailAddressesOutput == "json" {
+			return simpleGet(cmd, "/api/emailAddresses", "")
+		}
+		addresses, err := fetchEmailAddresses(cmd)
+		if err != nil {
+			return err
+		}
+		stats, err := emailAddressUsage(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "usage stats unavailable: %v\n", err)
+		}
+		return writeAddressTable(os.Stdout, addresses, stats)
+	},
+}
+
+var emailAddressShowCmd = &cobra.Command{
+	Use:   "show <address>",
+	Short: "Show an email address with its usage",
+	Long:  "Shows the description, creation date, email and file counts and the most recent emails received by one address.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if emailShowOutput != "json" && emailShowOutput != "text" {
+			return fmt.Errorf("invalid --output %q; valid values: text, json", emailShowOutput)
+		}
+		addresses, err := fetchEmailAddresses(cmd)
+		if err != nil {
+			return err
+		}
+		var addr *apiEmailAddress
+		for i := range addresses {
+			if strings.EqualFold(addresses[i].Address, args[0]) {
+				addr = &addresses[i]
+				break
+			}
+		}
+		if addr == nil {
+			return fmt.Errorf("email address %q not found", args[0])
+		}
+		stats, err := emailAddressUsage(cmd)
+		if err != nil {
+			return err
+		}
+		usage := stats[strings.ToLower(addr.Address)]
+
+		if emailShowOutput == "json" {
+			out := map[string]any{
+				"address":     addr.Address,
+				"description": addr.Description,
+				"created":     addr.CreatedAt,
+				"emails":      len(usage.Emails),
+				"files":       len(usage.Files),
+				"raw":         addr.Raw,
+			}
+			if !usage.Last.IsZero() {
+				out["last_email"] = usage.Last.Format(time.RFC3339)
+			}
+			b, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, string(b))
+		} else {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "Address:\t%s\n", addr.Address)
+			fmt.Fprintf(tw, "Description:\t%s\n", orDash(addr.Description))
+			fmt.Fprintf(tw, "Created:\t%s\n", formatLocal(addr.Created()))
+			fmt.Fprintf(tw, "Emails:\t%d\n", len(usage.Emails))
+			fmt.Fprintf(tw, "Files:\t%s\n", usage.filesCount(stats))
+			fmt.Fprintf(tw, "Last email:\t%s\n", formatLocal(usage.Last))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if recent := usage.recent(emailRecent); len(recent) > 0 {
+				fmt.Fprintln(os.Stdout)
+				fmt.Fprintln(os.Stdout, "Recent emails:")
+				if err := writeEmailTable(os.Stdout, recent, true); err != nil {
+					return err
+				}
+			}
+		}
+		if emailCopy {
+			return copyAddress(addr.Address)
+		}
+		return nil
+	},
+}
+
+func fetchEmailAddresses(cmd *cobra.Command) ([]apiEmailAddress, error) {
+	b, err := getBytes(cmd, "/api/emailAddresses")
+	if err != nil {
+		return nil, err
+	}
+	var addresses []apiEmailAddress
+	if err := decodeItems(b, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// addressUsage is what one inbound address has produced.
+type addressUsage struct {
+	Emails []apiEmail
+	Files  map[string]struct{}
+	Last   time.Time
+}
+
+// filesCount returns "-" when no email in the account reports file IDs, since
+// a zero would then say nothing.
+func (u addressUsage) filesCount(all map[string]addressUsage) string {
+	for _, other := range all {
+		if len(other.Files) > 0 {
+			return strconv.Itoa(len(u.Files))
+		}
+	}
+	return "-"
+}
+
+// recent returns the newest n emails, newest first.
+func (u addressUsage) recent(n int) []apiEmail {
+	out := make([]apiEmail, 0, min(n, len(u.Emails)))
+	for i := len(u.Emails) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, u.Emails[i])
+	}
+	return out
+}
+
+// emailAddressUsage groups all emails by the lower-cased receiving address.
+func emailAddressUsage(cmd *cobra.Command) (map[string]addressUsage, error) {
+	emails, err := listAllPages(cmd, "/api/emails", nil, 100, func(e apiEmail) string { return e.ID })
+	if err != nil {
+		return nil, err
+	}
+	sortEmails(emails)
+	stats := map[string]addressUsage{}
+	for _, e := range emails {
+		key := strings.ToLower(e.To)
+		u := stats[key]
+		if u.Files == nil {
+			u.Files = map[string]struct{}{}
+		}
+		u.Emails = append(u.Emails, e)
+		for _, id := range e.FileIDs {
+			u.Files[id] = struct{}{}
+		}
+		if r := e.Received(); r.After(u.Last) {
+			u.Last = r
+		}
+		stats[key] = u
+	}
+	return stats, nil
+}
+
+func writeAddressTable(w io.Writer, addresses []apiEmailAddress, stats map[string]addressUsage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tDESCRIPTION\tCREATED\tEMAILS\tFILES\tLAST EMAIL")
+	for _, a := range addresses {
+		emails, files, last := "-", "-", "-"
+		if stats != nil {
+			u := stats[strings.ToLower(a.Address)]
+			emails, files, last = strconv.Itoa(len(u.Emails)), u.filesCount(stats), formatLocal(u.Last)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", a.Address, truncate(orDash(a.Description), 40), formatLocal(a.Created()), emails, files, last)
+	}
+	return tw.Flush()
+}
+
+func copyAddress(address string) error {
+	if err := cli.CopyOSC52(address); err != nil {
+		return fmt.Errorf("copy to clipboard: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Copied %s to the clipboard\n", address)
+	return nil
+}
+
+func formatLocal(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	emailCmd.AddCommand(emailAddressesCmd)
+	emailAddressesCmd.AddCommand(emailAddressShowCmd)
+
+	emailAddressesCmd.Flags().StringVar(&emailAddressesOutput, "output", "json", "Output format: json, table")
+	emailAddressShowCmd.Flags().StringVar(&emailShowOutput, "output", "text", "Output format: text, json")
+	emailAddressShowCmd.Flags().BoolVar(&emailCopy, "copy", false, "Copy the address to the clipboard (OSC 52)")
+	emailAddressShowCmd.Flags().IntVar(&emailRecent, "recent", 5, "Number of recent emails to show")
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	emailEnvelopeFile = "email.json"
+
+	// maxProbedParts bounds the index probing for emails whose listing does
+	// not describe their body parts.
+	maxProbedParts = 50
+)
+
+var emailExportForce bool
+
+var emailExportCmd = &cobra.Command{
+	Use:   "export <dir>",
+	Short: "Export emails with all body parts",
+	Long: "Writes every email from /api/emails into its own folder under <dir>: an email.json envelope with sender, " +
+		"receiving address, subject, date and parts, plus one file per body part from /api/email/{id}/{index}. " +
+		"Folders that already contain email.json are skipped unless --force is given.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		since, err := parseSince(emailSince)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		emails, err := fetchEmails(cmd)
+		if err != nil {
+			return err
+		}
+
+		var exported, skipped, failed int
+		for _, e := range emails {
+			if emailAddress != "" && !strings.EqualFold(e.To, emailAddress) {
+				continue
+			}
+			if !since.IsZero() && !e.Received().After(since) {
+				continue
+			}
+			folder := filepath.Join(dir, emailFolderName(e))
+			if _, err := os.Stat(filepath.Join(folder, emailEnvelopeFile)); err == nil && !emailExportForce {
+				skipped++
+				continue
+			}
+			if err := exportEmail(cmd, folder, e); err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "export %s: %v\n", e.ID, err)
+				continue
+			}
+			exported++
+			fmt.Fprintln(os.Stdout, folder)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d emails, skipped %d already exported, %d failed\n", exported, skipped, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d emails failed to export", failed)
+		}
+		return nil
+	},
+}
+
+// emailEnvelope is the email.json written next to the exported parts.
+type emailEnvelope struct {
+	ID       string         `json:"id"`
+	From     string         `json:"from,omitempty"`
+	To       string         `json:"to,omitempty"`
+	Subject  string         `json:"subject,omitempty"`
+	Date     string         `json:"date,omitempty"`
+	Parts    []exportedPart `json:"parts"`
+	FileIDs  []string       `json:"file_ids,omitempty"`
+	Exported time.Time      `json:"exported"`
+	Raw      map[string]any `json:"raw"`
+}
+
+type exportedPart struct {
+	apiEmailPart
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func exportEmail(cmd *cobra.Command, folder string, e apiEmail) error {
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		return err
+	}
+	env := emailEnvelope{
+		ID:       e.ID,
+		From:     e.From,
+		To:       e.To,
+		Subject:  e.Subject,
+		Date:     e.Date,
+		FileIDs:  e.FileIDs,
+		Exported: time.Now().UTC(),
+		Raw:      e.Raw,
+	}
+
+	parts := e.Parts
+	probing := len(parts) == 0
+	if probing {
+		for i := 1; i <= maxProbedParts; i++ {
+			parts = append(parts, apiEmailPart{Index: i})
+		}
+	}
+	var partErr error
+	for _, p := range parts {
+		b, err := getBytes(cmd, fmt.Sprintf("/api/email/%s/%d", e.ID, p.Index))
+		if err != nil {
+			var httpErr *cli.HTTPError
+			if probing && errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusNotFound || httpErr.StatusCode == http.StatusBadRequest) {
+				break
+			}
+			env.Parts = append(env.Parts, exportedPart{apiEmailPart: p, Error: err.Error()})
+			partErr = fmt.Errorf("part %d: %w", p.Index, err)
+			continue
+		}
+		if p.ContentType == "" {
+			p.ContentType = cli.SniffContentType(b, p.Name)
+		}
+		name := emailPartFileName(p)
+		if err := os.WriteFile(filepath.Join(folder, name), b, 0o644); err != nil {
+			return err
+		}
+		env.Parts = append(env.Parts, exportedPart{apiEmailPart: p, File: name})
+	}
+
+	// The envelope is written last so a failed or interrupted export is
+	// retried on the next run.
+	if partErr != nil {
+		return partErr
+	}
+	b, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, emailEnvelopeFile), append(b, '\n'), 0o644)
+}
+
+// emailFolderName is "<date> <subject> (<id prefix>)", stable across runs.
+func emailFolderName(e apiEmail) string {
+	var parts []string
+	if d := e.Received(); !d.IsZero() {
+		parts = append(parts, d.Format(time.DateOnly))
+	}
+	if subject := sanitizeFileName(e.Subject); subject != "" {
+		parts = append(parts, subject)
+	}
+	short := e.ID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	parts = append(parts, "("+sanitizeFileName(short)+")")
+	return strings.Join(parts, " ")
+}
+
+func emailPartFileName(p apiEmailPart) string {
+	if name := sanitizeFileName(p.Name); name != "" {
+		return fmt.Sprintf("%d-%s", p.Index, name)
+	}
+	return fmt.Sprintf("%d-body%s", p.Index, extensionForType(p.ContentType))
+}
+
+func extensionForType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/plain":
+		return ".txt"
+	case "text/html":
+		return ".html"
+	case "application/pdf":
+		return ".pdf"
+	case "message/rfc822":
+		return ".eml"
+	case "":
+		return ".bin"
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+func init() {
+	emailCmd.AddCommand(emailExportCmd)
+
+	emailExportCmd.Flags().StringVar(&emailAddress, "address", "", "Only emails received by this Labradoc address")
+	emailExportCmd.Flags().StringVar(&emailSince, "since", "", "Only emails received after this date, timestamp or age (e.g. 2026-05-01, 36h, 7d)")
+	emailExportCmd.Flags().IntVar(&emailPageSize, "page-size", 100, "Emails per request")
+	emailExportCmd.Flags().BoolVar(&emailExportForce, "force", false, "Re-export emails that were already exported")
+}
@@ -0,0 +1,422 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	exportFormatMarkdownVault = "markdown-vault"
+
+	vaultBlockBegin = "<!-- labradoc:begin -->"
+	vaultBlockEnd   = "<!-- labradoc:end -->"
+	vaultAttachDir  = "attachments"
+)
+
+var (
+	exportFormat      string
+	exportAttachments bool
+	exportStatus      []string
+	exportPageSize    int
+)
+
+var filesExportCmd = &cobra.Command{
+	Use:   "export <dir>",
+	Short: "Export documents to a local directory",
+	Long: "Exports documents to a local directory. The markdown-vault format writes one Markdown note per document " +
+		"with metadata, status and extracted fields as YAML front matter, the OCR text as the body, open tasks as a " +
+		"checklist and related documents as wiki-links. Re-running updates notes in place; text outside the " +
+		"generated block is kept.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportFormat != exportFormatMarkdownVault {
+			return fmt.Errorf("invalid --format %q; valid values: %s", exportFormat, exportFormatMarkdownVault)
+		}
+		statuses, err := validateStatuses(exportStatus)
+		if err != nil {
+			return err
+		}
+		dir := args[0]
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		files, err := listAllFiles(cmd, statuses, exportPageSize)
+		if err != nil {
+			return err
+		}
+		existing, err := indexVaultNotes(dir)
+		if err != nil {
+			return err
+		}
+		names := make(map[string]string, len(files))
+		for _, f := range files {
+			if path, ok := existing[f.ID]; ok {
+				names[f.ID] = strings.TrimSuffix(filepath.Base(path), ".md")
+			} else {
+				names[f.ID] = vaultNoteName(f)
+			}
+		}
+
+		var created, updated, failed int
+		for _, f := range files {
+			path := filepath.Join(dir, names[f.ID]+".md")
+			if old, ok := existing[f.ID]; ok {
+				path = old
+			}
+			_, statErr := os.Stat(path)
+			if err := exportVaultNote(cmd, dir, path, f, names); err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "export %s: %v\n", f.ID, err)
+				continue
+			}
+			if statErr == nil {
+				updated++
+			} else {
+				created++
+			}
+		}
+
+		fmt.Fprintf(os.Stdout, "Exported %d notes to %s (%d created, %d updated, %d failed)\n", created+updated, dir, created, updated, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d documents failed to export", failed)
+		}
+		return nil
+	},
+}
+
+type vaultFrontMatter struct {
+	LabradocID  string         `yaml:"labradoc_id"`
+	Title       string         `yaml:"title"`
+	Status      string         `yaml:"status,omitempty"`
+	ContentType string         `yaml:"content_type,omitempty"`
+	Created     string         `yaml:"created,omitempty"`
+	Attachment  string         `yaml:"attachment,omitempty"`
+	Tags        []string       `yaml:"tags"`
+	Fields      map[string]any `yaml:"fields,omitempty"`
+	Metadata    map[string]any `yaml:"metadata,omitempty"`
+}
+
+func exportVaultNote(cmd *cobra.Command, dir, path string, f apiFile, names map[string]string) error {
+	base := fmt.Sprintf("/api/user/files/%s", f.ID)
+
+	// A failed fetch skips the note rather than rewriting it without the
+	// missing part; only sub-resources the document does not have are empty.
+	b, err := getOptionalBytes(cmd, base+"/fields")
+	if err != nil {
+		return fmt.Errorf("fields: %w", err)
+	}
+	var fields map[string]any
+	if b != nil {
+		fields = normalizeFields(b)
+	}
+
+	if b, err = getOptionalBytes(cmd, base+"/ocr"); err != nil {
+		return fmt.Errorf("ocr: %w", err)
+	}
+	var text string
+	if b != nil {
+		out, err := cli.ConvertOCR(b, cli.TextConvertOptions{Format: cli.TextFormatMarkdown})
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(string(out))
+	}
+
+	if b, err = getOptionalBytes(cmd, base+"/tasks"); err != nil {
+		return fmt.Errorf("tasks: %w", err)
+	}
+	var tasks []apiTask
+	if b != nil {
+		if err := decodeItems(b, &tasks); err != nil {
+			return fmt.Errorf("decode tasks: %w", err)
+		}
+	}
+
+	if b, err = getOptionalBytes(cmd, base+"/related"); err != nil {
+		return fmt.Errorf("related: %w", err)
+	}
+	var related []apiFile
+	if b != nil {
+		if err := decodeItems(b, &related); err != nil {
+			return fmt.Errorf("decode related: %w", err)
+		}
+	}
+
+	var attachment string
+	if exportAttachments {
+		rel, err := downloadAttachment(cmd, dir, names[f.ID], f)
+		if err != nil {
+			return err
+		}
+		attachment = filepath.ToSlash(rel)
+	}
+
+	fm := vaultFrontMatter{
+		LabradocID:  f.ID,
+		Title:       f.Name,
+		Status:      f.Status,
+		ContentType: f.ContentType,
+		Created:     f.CreatedAt,
+		Attachment:  attachment,
+		Tags:        []string{"labradoc"},
+		Fields:      fields,
+		Metadata:    scalarFields(f.Raw),
+	}
+	if fm.Title == "" {
+		fm.Title = f.ID
+	}
+	var head bytes.Buffer
+	enc := yaml.NewEncoder(&head)
+	enc.SetIndent(2)
+	if err := enc.Encode(fm); err != nil {
+		return err
+	}
+
+	var block strings.Builder
+	block.WriteString(vaultBlockBegin + "\n")
+	fmt.Fprintf(&block, "# %s\n", fm.Title)
+	if attachment != "" {
+		fmt.Fprintf(&block, "\n![[%s]]\n", attachment)
+	}
+	var open []apiTask
+	for _, t := range tasks {
+		if !t.Closed {
+			open = append(open, t)
+		}
+	}
+	if len(open) > 0 {
+		block.WriteString("\n## Tasks\n\n")
+		for _, t := range open {
+			line := t.Title
+			if t.DueDate != "" {
+				line += " (due " + t.DueDate + ")"
+			}
+			fmt.Fprintf(&block, "- [ ] %s\n", line)
+		}
+	}
+	if len(related) > 0 {
+		block.WriteString("\n## Related\n\n")
+		for _, r := range related {
+			name, ok := names[r.ID]
+			if !ok {
+				name = vaultNoteName(r)
+			}
+			fmt.Fprintf(&block, "- [[%s]]\n", name)
+		}
+	}
+	if text != "" {
+		block.WriteString("\n## Text\n\n")
+		block.WriteString(text)
+		block.WriteString("\n")
+	}
+	block.WriteString(vaultBlockEnd + "\n")
+
+	before, after := "", ""
+	if b, err := os.ReadFile(path); err == nil {
+		before, after = splitVaultNote(string(b))
+	}
+
+	var note strings.Builder
+	note.WriteString("---\n")
+	note.Write(head.Bytes())
+	note.WriteString("---\n")
+	note.WriteString(before)
+	note.WriteString(block.String())
+	note.WriteString(after)
+	return os.WriteFile(path, []byte(note.String()), 0o644)
+}
+
+// splitVaultNote returns the user-written text around the generated block of
+// an existing note, without its front matter.
+func splitVaultNote(s string) (string, string) {
+	if strings.HasPrefix(s, "---\n") {
+		if end := strings.Index(s[4:], "\n---\n"); end >= 0 {
+			s = s[4+end+5:]
+		}
+	}
+	begin := strings.Index(s, vaultBlockBegin)
+	end := strings.Index(s, vaultBlockEnd)
+	if begin < 0 || end < begin {
+		if strings.TrimSpace(s) == "" {
+			return "", ""
+		}
+		return "", "\n" + s
+	}
+	after := s[end+len(vaultBlockEnd):]
+	after = strings.TrimPrefix(after, "\n")
+	return s[:begin], after
+}
+
+// indexVaultNotes maps labradoc_id front matter values to note paths so that
+// renamed documents keep updating the same note.
+func indexVaultNotes(dir string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]string, len(matches))
+	for _, path := range matches {
+		if id := readFrontMatterID(path); id != "" {
+			index[id] = path
+		}
+	}
+	return index, nil
+}
+
+func readFrontMatterID(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if i == 0 {
+			if line != "---" {
+				return ""
+			}
+			continue
+		}
+		if line == "---" {
+			return ""
+		}
+		if v, ok := strings.CutPrefix(line, "labradoc_id:"); ok {
+			return strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	}
+	return ""
+}
+
+func downloadAttachment(cmd *cobra.Command, dir, name string, f apiFile) (string, error) {
+	rel := filepath.Join(vaultAttachDir, name+attachmentExt(f))
+	path := filepath.Join(dir, rel)
+	if _, err := os.Stat(path); err == nil {
+		return rel, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	b, err := getBytes(cmd, fmt.Sprintf("/api/user/files/%s/download", f.ID))
+	if err != nil {
+		return "", fmt.Errorf("download: %w", err)
+	}
+	return rel, os.WriteFile(path, b, 0o644)
+}
+
+func attachmentExt(f apiFile) string {
+	if ext := filepath.Ext(f.Name); ext != "" && len(ext) <= 6 {
+		return strings.ToLower(ext)
+	}
+	if f.ContentType != "" {
+		if exts, err := mime.ExtensionsByType(f.ContentType); err == nil && len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ".pdf"
+}
+
+func vaultNoteName(f apiFile) string {
+	name := sanitizeFileName(strings.TrimSuffix(f.Name, filepath.Ext(f.Name)))
+	short := f.ID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	if name == "" {
+		return f.ID
+	}
+	return fmt.Sprintf("%s (%s)", name, short)
+}
+
+// sanitizeFileName replaces characters that are invalid in file names or
+// that break wiki-links, and caps the length.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', '#', '^', '[', ']':
+			return '-'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > 80 {
+		name = strings.TrimSpace(string(runes[:80]))
+	}
+	return name
+}
+
+// normalizeFields turns the /fields response into a flat map. Both an object
+// and a list of {name|key|label, value} entries are accepted.
+func normalizeFields(b []byte) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal(b, &obj); err == nil {
+		if inner, ok := obj["fields"]; ok {
+			b, _ = json.Marshal(inner)
+		} else {
+			return obj
+		}
+	}
+	var list []map[string]any
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil
+	}
+	out := make(map[string]any, len(list))
+	for _, item := range list {
+		key := stringField(item, "name", "key", "label", "field")
+		if key == "" {
+			continue
+		}
+		out[key] = item["value"]
+	}
+	return out
+}
+
+func scalarFields(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		switch v.(type) {
+		case string, float64, bool:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func validateStatuses(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		status := strings.TrimSpace(s)
+		if status == "" {
+			continue
+		}
+		if _, ok := fileStatusSet[status]; !ok {
+			return nil, fmt.Errorf("invalid status %q; valid values: %s", status, strings.Join(fileStatusOptions, ", "))
+		}
+		out = append(out, status)
+	}
+	return out, nil
+}
+
+func init() {
+	filesCmd.AddCommand(filesExportCmd)
+
+	filesExportCmd.Flags().StringVar(&exportFormat, "format", exportFormatMarkdownVault, "Export format: "+exportFormatMarkdownVault)
+	filesExportCmd.Flags().BoolVar(&exportAttachments, "attachments", false, "Copy the original file into an attachments folder next to each note")
+	filesExportCmd.Flags().StringSliceVar(&exportStatus, "status", nil, "Only export files with this status (repeatable)")
+	filesExportCmd.Flags().IntVar(&exportPageSize, "page-size", 100, "Page size used when listing files")
+}
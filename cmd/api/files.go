@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/zamedic/labradoc-cli/internal/cli"
@@ -114,12 +117,19 @@ var (
 )
 
 var filesUploadCmd = &cobra.Command{
-	Use:   "upload",
+	Use:   "upload [images...]",
 	Short: "Upload files",
-	Long:  "Uploads files.",
-	RunE: func(cmd *cobra.Command, _ []string) error {
-		if uploadFilePath == "" {
-			return fmt.Errorf("missing --file")
+	Long: "Uploads files. With --from-mail, uploads the PDF, image and Office attachments found in an .eml file, .mbox file or Maildir folder. " +
+		"With --combine, assembles the image arguments into a single multi-page PDF locally and uploads it as one document.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && !uploadCombine {
+			return fmt.Errorf("unexpected arguments %v (use --file, or --combine for images)", args)
+		}
+		if uploadFilePath == "" && uploadFromMail == "" && !uploadCombine {
+			return fmt.Errorf("missing --file, --from-mail or --combine")
+		}
+		if err := validatePreflightMode(); err != nil {
+			return err
 		}
 		opts, err := resolveAPIConfig()
 		if err != nil {
@@ -128,30 +138,28 @@ var filesUploadCmd = &cobra.Command{
 		if opts.APIKey == "" && opts.Token == "" {
 			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
 		}
-
-		f, err := os.Open(uploadFilePath)
-		if err != nil {
-			return err
+		if uploadFromMail != "" {
+			return runMailUpload(cmd, opts)
 		}
-		defer f.Close()
 
-		var body bytes.Buffer
-		writer := multipart.NewWriter(&body)
-		part, err := writer.CreateFormFile("file", filepath.Base(uploadFilePath))
-		if err != nil {
-			return err
+		var (
+			name string
+			data []byte
+		)
+		if uploadCombine {
+			name, data, err = combineImages(args)
+		} else {
+			name = filepath.Base(uploadFilePath)
+			data, err = os.ReadFile(uploadFilePath)
 		}
-		if _, err := io.Copy(part, f); err != nil {
+		if err != nil {
 			return err
 		}
-		if err := writer.Close(); err != nil {
+		if err := preflightUpload(name, data); err != nil {
 			return err
 		}
 
-		opts.Headers = map[string]string{
-			"Content-Type": writer.FormDataContentType(),
-		}
-		resp, err := cli.DoRequest(cmd.Context(), "PUT", "/api/user/files", &body, opts)
+		resp, err := uploadMultipart(cmd, opts, data, name)
 		if err != nil {
 			return err
 		}
@@ -166,9 +174,73 @@ var filesUploadCmd = &cobra.Command{
 	},
 }
 
+// uploadMultipart PUTs data to /api/user/files as the "file" form part, with
+// the part's Content-Type sniffed from the content.
+func uploadMultipart(cmd *cobra.Command, opts cli.RequestOptions, data []byte, filename string) (*http.Response, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": filename,
+	}))
+	header.Set("Content-Type", cli.SniffContentType(data, filename))
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	opts.Headers = map[string]string{
+		"Content-Type": writer.FormDataContentType(),
+	}
+	return cli.DoRequest(cmd.Context(), "PUT", "/api/user/files", &body, opts)
+}
+
+// uploadFile uploads data and decodes the created file from the response.
+func uploadFile(cmd *cobra.Command, opts cli.RequestOptions, data []byte, filename string) (*apiFile, error) {
+	resp, err := uploadMultipart(cmd, opts, data, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("upload failed: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var files []apiFile
+		if err := json.Unmarshal(trimmed, &files); err != nil {
+			return nil, fmt.Errorf("decode upload response: %w", err)
+		}
+		if len(files) > 0 {
+			return &files[0], nil
+		}
+		return &apiFile{}, nil
+	}
+	var f apiFile
+	if len(trimmed) > 0 {
+		if err := json.Unmarshal(trimmed, &f); err != nil {
+			return nil, fmt.Errorf("decode upload response: %w", err)
+		}
+	}
+	return &f, nil
+}
+
 var (
-	fileID       string
-	filesOutPath string
+	fileID           string
+	filesOutPath     string
+	filesFormat      string
+	filesPageMarkers bool
 )
 
 var filesGetCmd = &cobra.Command{
@@ -189,7 +261,7 @@ var filesContentCmd = &cobra.Command{
 		if fileID == "" {
 			return fmt.Errorf("missing --id")
 		}
-		return simpleGet(cmd, fmt.Sprintf("/api/user/files/%s/content", fileID), filesOutPath)
+		return formattedGet(cmd, fmt.Sprintf("/api/user/files/%s/content", fileID), filesOutPath)
 	},
 }
 
@@ -200,7 +272,7 @@ var filesOcrCmd = &cobra.Command{
 		if fileID == "" {
 			return fmt.Errorf("missing --id")
 		}
-		return simpleGet(cmd, fmt.Sprintf("/api/user/files/%s/ocr", fileID), filesOutPath)
+		return formattedGet(cmd, fmt.Sprintf("/api/user/files/%s/ocr", fileID), filesOutPath)
 	},
 }
 
@@ -407,6 +479,11 @@ func init() {
 	filesImageCmd.Flags().StringVar(&filesOutPath, "out", "", "Write response to file instead of stdout")
 	filesPreviewCmd.Flags().StringVar(&filesOutPath, "out", "", "Write response to file instead of stdout")
 
+	filesContentCmd.Flags().StringVar(&filesFormat, "format", "", "Convert the response: "+strings.Join(cli.TextFormatOptions, ", ")+" (default raw)")
+	filesOcrCmd.Flags().StringVar(&filesFormat, "format", "", "Convert the response: "+strings.Join(cli.TextFormatOptions, ", ")+" (default raw)")
+	filesContentCmd.Flags().BoolVar(&filesPageMarkers, "page-markers", false, "Include page markers in text and markdown output")
+	filesOcrCmd.Flags().BoolVar(&filesPageMarkers, "page-markers", false, "Include page markers in text and markdown output")
+
 	filesQuestionCmd.Flags().StringVar(&questionText, "question", "", "Question text (JSON field: question)")
 	filesQuestionCmd.Flags().StringVar(&bodyText, "body", "", "Request body as a JSON string")
 	filesQuestionCmd.Flags().StringVar(&bodyFile, "body-file", "", "Request body JSON file ('-' for stdin)")
@@ -468,6 +545,50 @@ func simpleGet(cmd *cobra.Command, path string, outPath string) error {
 	return writeResponse(resp, outPath)
 }
 
+func formattedGet(cmd *cobra.Command, path string, outPath string) error {
+	if filesFormat == "" {
+		return simpleGet(cmd, path, outPath)
+	}
+	if !slices.Contains(cli.TextFormatOptions, filesFormat) {
+		return fmt.Errorf("invalid --format %q; valid values: %s", filesFormat, strings.Join(cli.TextFormatOptions, ", "))
+	}
+	opts, err := resolveAPIConfig()
+	if err != nil {
+		return err
+	}
+	if opts.APIKey == "" && opts.Token == "" {
+		return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+	}
+	resp, err := cli.DoRequest(cmd.Context(), "GET", path, nil, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return writeResponse(resp, outPath)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	out, err := cli.ConvertOCR(raw, cli.TextConvertOptions{
+		Format:      filesFormat,
+		PageMarkers: filesPageMarkers,
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(out, outPath)
+}
+
+func writeOutput(b []byte, outPath string) error {
+	if outPath == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(outPath, b, 0o644)
+}
+
 func simplePost(cmd *cobra.Command, path string, body io.Reader, contentType string, outPath string) error {
 	opts, err := resolveAPIConfig()
 	if err != nil {
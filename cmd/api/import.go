@@ -0,0 +1,318 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const paperlessMappingFileName = "labradoc-import.jsonl"
+
+var filesImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import documents from other document systems",
+}
+
+var (
+	importMappingPath string
+	importDryRun      bool
+	importUseTitle    bool
+	importVerify      bool
+	importJSON        bool
+)
+
+var filesImportPaperlessCmd = &cobra.Command{
+	Use:   "paperless <export-dir>",
+	Short: "Import a Paperless-ngx document_exporter directory",
+	Long: "Reads manifest.json from a Paperless-ngx document_exporter directory and uploads each original via PUT /api/user/files. " +
+		"A mapping file (JSON Lines, one line appended per upload) records Paperless ID to Labradoc ID so re-runs skip " +
+		"documents that were already imported, and keeps the title, tags, correspondent, document type, created date " +
+		"and notes that the API cannot accept.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		docs, err := readPaperlessManifest(dir)
+		if err != nil {
+			return err
+		}
+
+		mappingPath := importMappingPath
+		if mappingPath == "" {
+			mappingPath = filepath.Join(dir, paperlessMappingFileName)
+		}
+		mapping, err := loadImportMapping(mappingPath)
+		if err != nil {
+			return err
+		}
+
+		opts, err := resolveAPIConfig()
+		if err != nil {
+			return err
+		}
+		if !importDryRun && opts.APIKey == "" && opts.Token == "" {
+			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+		}
+		var journal *os.File
+		if !importDryRun {
+			if journal, err = os.OpenFile(mappingPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+				return err
+			}
+			defer journal.Close()
+		}
+		enc := json.NewEncoder(journal)
+
+		report := importReport{Total: len(docs), Mapping: mappingPath, DryRun: importDryRun}
+		for _, doc := range docs {
+			key := strconv.Itoa(doc.PaperlessID)
+			if prev, ok := mapping[key]; ok && !prev.ImportedAt.IsZero() {
+				report.Skipped++
+				continue
+			}
+			path := filepath.Join(dir, doc.File)
+			if doc.File == "" {
+				report.missing(doc, "manifest entry has no exported file")
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				report.missing(doc, err.Error())
+				continue
+			}
+			name := filepath.Base(doc.File)
+			if importUseTitle && doc.Title != "" {
+				if title := sanitizeFileName(doc.Title); title != "" {
+					name = title + filepath.Ext(doc.File)
+				}
+			}
+			if importDryRun {
+				report.Imported++
+				continue
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				report.fail(doc, err.Error())
+				continue
+			}
+			created, err := uploadFile(cmd, opts, data, name)
+			if err != nil {
+				report.fail(doc, err.Error())
+				continue
+			}
+			doc.LabradocID = created.ID
+			doc.UploadedName = name
+			doc.ImportedAt = time.Now().UTC()
+			mapping[key] = doc
+			if err := enc.Encode(doc); err != nil {
+				return fmt.Errorf("record paperless %d -> %s in %s: %w", doc.PaperlessID, doc.LabradocID, mappingPath, err)
+			}
+			report.Imported++
+		}
+
+		if importVerify && !importDryRun {
+			for _, doc := range mapping {
+				if doc.LabradocID == "" {
+					continue
+				}
+				if _, err := getBytes(cmd, fmt.Sprintf("/api/user/files/%s", doc.LabradocID)); err != nil {
+					report.NotFound = append(report.NotFound, doc.LabradocID)
+				} else {
+					report.Verified++
+				}
+			}
+			sort.Strings(report.NotFound)
+		}
+
+		if importJSON {
+			b, _ := json.Marshal(report)
+			fmt.Fprintln(os.Stdout, string(b))
+		} else {
+			report.print()
+		}
+		if len(report.Failures) > 0 {
+			return fmt.Errorf("%d documents failed to import", len(report.Failures))
+		}
+		return nil
+	},
+}
+
+// paperlessDocument is one imported document in the mapping file. Besides
+// the IDs it keeps only the metadata Labradoc has no field for; the raw
+// Paperless record (with its OCR content) is not stored.
+type paperlessDocument struct {
+	PaperlessID   int       `json:"paperless_id"`
+	LabradocID    string    `json:"labradoc_id,omitempty"`
+	File          string    `json:"file"`
+	UploadedName  string    `json:"uploaded_name,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	Correspondent string    `json:"correspondent,omitempty"`
+	DocumentType  string    `json:"document_type,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Created       string    `json:"created,omitempty"`
+	Notes         []string  `json:"notes,omitempty"`
+	ImportedAt    time.Time `json:"imported_at,omitzero"`
+}
+
+type importFailure struct {
+	PaperlessID int    `json:"paperless_id"`
+	File        string `json:"file"`
+	Error       string `json:"error"`
+}
+
+type importReport struct {
+	Total    int             `json:"total"`
+	Imported int             `json:"imported"`
+	Skipped  int             `json:"skipped"`
+	Missing  int             `json:"missing"`
+	Failed   int             `json:"failed"`
+	Verified int             `json:"verified,omitempty"`
+	NotFound []string        `json:"not_found,omitempty"`
+	Failures []importFailure `json:"failures,omitempty"`
+	Mapping  string          `json:"mapping"`
+	DryRun   bool            `json:"dry_run,omitempty"`
+}
+
+func (r *importReport) fail(doc paperlessDocument, msg string) {
+	r.Failed++
+	r.Failures = append(r.Failures, importFailure{PaperlessID: doc.PaperlessID, File: doc.File, Error: msg})
+}
+
+func (r *importReport) missing(doc paperlessDocument, msg string) {
+	r.Missing++
+	r.Failures = append(r.Failures, importFailure{PaperlessID: doc.PaperlessID, File: doc.File, Error: msg})
+}
+
+func (r *importReport) print() {
+	verb := "imported"
+	if r.DryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(os.Stdout, "Paperless documents in manifest: %d\n", r.Total)
+	fmt.Fprintf(os.Stdout, "  %s: %d\n", verb, r.Imported)
+	fmt.Fprintf(os.Stdout, "  already imported: %d\n", r.Skipped)
+	fmt.Fprintf(os.Stdout, "  missing originals: %d\n", r.Missing)
+	fmt.Fprintf(os.Stdout, "  failed: %d\n", r.Failed)
+	if r.Verified > 0 || len(r.NotFound) > 0 {
+		fmt.Fprintf(os.Stdout, "  verified in Labradoc: %d\n", r.Verified)
+		for _, id := range r.NotFound {
+			fmt.Fprintf(os.Stdout, "  not found in Labradoc: %s\n", id)
+		}
+	}
+	for _, f := range r.Failures {
+		fmt.Fprintf(os.Stdout, "  error: paperless %d (%s): %s\n", f.PaperlessID, f.File, f.Error)
+	}
+	fmt.Fprintf(os.Stdout, "Mapping: %s\n", r.Mapping)
+}
+
+type paperlessRecord struct {
+	Model            string         `json:"model"`
+	PK               int            `json:"pk"`
+	Fields           map[string]any `json:"fields"`
+	ExportedFileName string         `json:"__exported_file_name__"`
+}
+
+// readPaperlessManifest resolves tag, correspondent and document type
+// references in manifest.json and returns the documents in manifest order.
+func readPaperlessManifest(dir string) ([]paperlessDocument, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []paperlessRecord
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("parse manifest.json: %w", err)
+	}
+
+	names := map[string]map[int]string{}
+	notes := map[int][]string{}
+	for _, rec := range records {
+		switch rec.Model {
+		case "documents.tag", "documents.correspondent", "documents.documenttype":
+			if names[rec.Model] == nil {
+				names[rec.Model] = map[int]string{}
+			}
+			names[rec.Model][rec.PK] = stringField(rec.Fields, "name")
+		case "documents.note":
+			if doc, ok := rec.Fields["document"].(float64); ok {
+				notes[int(doc)] = append(notes[int(doc)], stringField(rec.Fields, "note"))
+			}
+		}
+	}
+	lookup := func(model string, v any) string {
+		if id, ok := v.(float64); ok {
+			return names[model][int(id)]
+		}
+		return ""
+	}
+
+	var docs []paperlessDocument
+	for _, rec := range records {
+		if rec.Model != "documents.document" {
+			continue
+		}
+		doc := paperlessDocument{
+			PaperlessID:   rec.PK,
+			File:          rec.ExportedFileName,
+			Title:         stringField(rec.Fields, "title"),
+			Correspondent: lookup("documents.correspondent", rec.Fields["correspondent"]),
+			DocumentType:  lookup("documents.documenttype", rec.Fields["document_type"]),
+			Created:       stringField(rec.Fields, "created"),
+			Notes:         notes[rec.PK],
+		}
+		if tags, ok := rec.Fields["tags"].([]any); ok {
+			for _, t := range tags {
+				if name := lookup("documents.tag", t); name != "" {
+					doc.Tags = append(doc.Tags, name)
+				}
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// loadImportMapping reads the mapping file, one JSON document per line, keyed
+// by Paperless ID. Later lines win. A missing file is an empty mapping.
+func loadImportMapping(path string) (map[string]paperlessDocument, error) {
+	m := map[string]paperlessDocument{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var doc paperlessDocument
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s line %d: %w", path, n, err)
+		}
+		m[strconv.Itoa(doc.PaperlessID)] = doc
+	}
+	return m, scanner.Err()
+}
+
+func init() {
+	filesCmd.AddCommand(filesImportCmd)
+	filesImportCmd.AddCommand(filesImportPaperlessCmd)
+
+	filesImportPaperlessCmd.Flags().StringVar(&importMappingPath, "mapping", "", "Mapping file (default <export-dir>/"+paperlessMappingFileName+")")
+	filesImportPaperlessCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Report what would be imported without uploading")
+	filesImportPaperlessCmd.Flags().BoolVar(&importUseTitle, "use-title", true, "Upload using the Paperless title as the file name")
+	filesImportPaperlessCmd.Flags().BoolVar(&importVerify, "verify", false, "Check that every mapped Labradoc ID still exists")
+	filesImportPaperlessCmd.Flags().BoolVar(&importJSON, "json", false, "Output the reconciliation report as JSON")
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	mailBodyNone = "none"
+	mailBodyHTML = "html"
+	mailBodyText = "text"
+	mailBodyAuto = "auto"
+)
+
+var (
+	uploadFromMail   string
+	uploadMailBody   string
+	uploadMailLog    string
+	uploadMailInline bool
+)
+
+var mailAttachmentExts = map[string]struct{}{
+	".pdf": {}, ".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".tif": {}, ".tiff": {},
+	".bmp": {}, ".webp": {}, ".heic": {}, ".doc": {}, ".docx": {}, ".xls": {}, ".xlsx": {},
+	".ppt": {}, ".pptx": {}, ".odt": {}, ".ods": {}, ".odp": {}, ".rtf": {},
+}
+
+// mailLogEntry is one line of the provenance log written by --from-mail.
+type mailLogEntry struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	MessageID  string    `json:"message_id"`
+	From       string    `json:"from"`
+	Date       time.Time `json:"date,omitzero"`
+	Subject    string    `json:"subject,omitempty"`
+	Part       string    `json:"part"`
+	Filename   string    `json:"filename"`
+	LabradocID string    `json:"labradoc_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func runMailUpload(cmd *cobra.Command, opts cli.RequestOptions) error {
+	switch uploadMailBody {
+	case mailBodyNone, mailBodyHTML, mailBodyText, mailBodyAuto:
+	default:
+		return fmt.Errorf("invalid --mail-body %q; valid values: none, html, text, auto", uploadMailBody)
+	}
+
+	done, err := readMailLog(uploadMailLog)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(uploadMailLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	enc := json.NewEncoder(logFile)
+
+	var uploaded, skipped, rejected, failed int
+	upload := func(msg cli.MailMessage, part, filename string, data []byte) error {
+		key := mailLogKey(msg.MessageID, part, filename)
+		if _, ok := done[key]; ok && msg.MessageID != "" {
+			skipped++
+			return nil
+		}
+		entry := mailLogEntry{
+			Time:      time.Now().UTC(),
+			Source:    msg.Source,
+			MessageID: msg.MessageID,
+			From:      msg.From,
+			Date:      msg.Date,
+			Subject:   msg.Subject,
+			Part:      part,
+			Filename:  filename,
+		}
+		if err := preflightUpload(filename, data); err != nil {
+			rejected++
+			fmt.Fprintf(os.Stderr, "skip %s from %s: %v\n", filename, msg.Source, err)
+			return nil
+		}
+		f, err := uploadFile(cmd, opts, data, filename)
+		if err != nil {
+			failed++
+			entry.Error = err.Error()
+			fmt.Fprintf(os.Stderr, "upload %s from %s: %v\n", filename, msg.Source, err)
+		} else {
+			uploaded++
+			entry.LabradocID = f.ID
+			done[key] = struct{}{}
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n", f.ID, filename, msg.MessageID)
+		}
+		return enc.Encode(entry)
+	}
+
+	err = cli.WalkMail(uploadFromMail, func(msg cli.MailMessage) error {
+		for i, a := range msg.Attachments {
+			if a.Inline && !uploadMailInline {
+				continue
+			}
+			if _, ok := mailAttachmentExts[strings.ToLower(filepath.Ext(a.Filename))]; !ok {
+				continue
+			}
+			// Keyed by position so two parts with the same filename are both uploaded.
+			if err := upload(msg, fmt.Sprintf("attachment/%d", i), a.Filename, a.Data); err != nil {
+				return err
+			}
+		}
+		name, data := mailBodyDocument(msg)
+		if data == nil {
+			return nil
+		}
+		return upload(msg, "body", name, data)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Uploaded %d, skipped %d already uploaded, rejected %d, %d failed (log: %s)\n", uploaded, skipped, rejected, failed, uploadMailLog)
+	if failed > 0 {
+		return fmt.Errorf("%d uploads failed", failed)
+	}
+	return nil
+}
+
+func mailBodyDocument(msg cli.MailMessage) (string, []byte) {
+	base := sanitizeFileName(msg.Subject)
+	if base == "" {
+		base = "email"
+	}
+	switch uploadMailBody {
+	case mailBodyHTML:
+		if msg.HTMLBody != nil {
+			return base + ".html", msg.HTMLBody
+		}
+	case mailBodyText:
+		if msg.TextBody != nil {
+			return base + ".txt", msg.TextBody
+		}
+	case mailBodyAuto:
+		if msg.HTMLBody != nil {
+			return base + ".html", msg.HTMLBody
+		}
+		if msg.TextBody != nil {
+			return base + ".txt", msg.TextBody
+		}
+	}
+	return "", nil
+}
+
+func mailLogKey(messageID, part, filename string) string {
+	return messageID + "\x00" + part + "\x00" + filename
+}
+
+// readMailLog returns the parts that were already uploaded so re-runs over the
+// same archive skip them.
+func readMailLog(path string) (map[string]struct{}, error) {
+	done := map[string]struct{}{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e mailLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.LabradocID != "" && e.Error == "" {
+			done[mailLogKey(e.MessageID, e.Part, e.Filename)] = struct{}{}
+		}
+	}
+	return done, scanner.Err()
+}
+
+func init() {
+	filesUploadCmd.Flags().StringVar(&uploadFromMail, "from-mail", "", "Upload attachments from an .eml file, .mbox file or Maildir folder")
+	filesUploadCmd.Flags().StringVar(&uploadMailBody, "mail-body", mailBodyNone, "Also upload each message body: none, html, text, auto")
+	filesUploadCmd.Flags().StringVar(&uploadMailLog, "mail-log", "labradoc-mail-upload.jsonl", "Provenance log for --from-mail uploads")
+	filesUploadCmd.Flags().BoolVar(&uploadMailInline, "mail-inline", false, "Also upload inline parts referenced by Content-ID, such as signature images")
+	// Declared here because init runs after files.go and combine.go have
+	// registered --file and --combine.
+	filesUploadCmd.MarkFlagsMutuallyExclusive("combine", "file", "from-mail")
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+// apiFile is a document as returned by /api/user/files. Raw keeps the full
+// payload so callers can surface fields that are not modelled here.
+type apiFile struct {
+	ID          string
+	Name        string
+	Status      string
+	ContentType string
+	CreatedAt   string
+	Archived    bool
+	Raw         map[string]any
+}
+
+func (f *apiFile) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	f.Raw = m
+	f.ID = stringField(m, "id", "fileId", "uuid")
+	f.Name = stringField(m, "name", "fileName", "filename", "originalName", "title")
+	f.Status = stringField(m, "status", "state")
+	f.ContentType = stringField(m, "contentType", "mimeType", "type")
+	f.CreatedAt = stringField(m, "createdAt", "created", "createdDate", "uploadedAt")
+	f.Archived = boolField(m, "archived", "isArchived") || strings.EqualFold(f.Status, "archived")
+	return nil
+}
+
+// apiTask is a task as returned by /api/tasks and /api/user/files/{id}/tasks.
+type apiTask struct {
+	ID          string
+	Title       string
+	Description string
+	Status      string
+	Closed      bool
+	DueDate     string
+	CreatedAt   string
+	FileID      string
+	FileName    string
+	Raw         map[string]any
+}
+
+// Due returns the parsed due date, or the zero time when there is none.
+func (t apiTask) Due() time.Time {
+	return parseAPITime(t.DueDate)
+}
+
+// DueDay returns the calendar day the task is due in the local time zone, as
+// local midnight, or the zero time when unknown. Dates without a zone are
+// taken as local dates.
+func (t apiTask) DueDay() time.Time {
+	return parseLocalDay(t.DueDate)
+}
+
+// Created returns the parsed creation time, or the zero time when unknown.
+func (t apiTask) Created() time.Time {
+	return parseAPITime(t.CreatedAt)
+}
+
+func (t *apiTask) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	t.Raw = m
+	t.ID = stringField(m, "id", "taskId", "uuid")
+	t.Title = stringField(m, "title", "name", "summary")
+	t.Description = stringField(m, "description", "details")
+	t.Status = stringField(m, "status", "state")
+	t.DueDate = stringField(m, "dueDate", "due", "dueAt", "deadline")
+	t.CreatedAt = stringField(m, "createdAt", "created", "createdDate")
+	t.FileID = stringField(m, "fileId", "documentId", "file_id")
+	t.FileName = stringField(m, "fileName", "documentName", "filename")
+	if file, ok := m["file"].(map[string]any); ok {
+		if t.FileID == "" {
+			t.FileID = stringField(file, "id")
+		}
+		if t.FileName == "" {
+			t.FileName = stringField(file, "name", "fileName", "filename")
+		}
+	}
+	t.Closed = boolField(m, "closed", "done", "completed")
+	switch strings.ToLower(t.Status) {
+	case "closed", "done", "completed":
+		t.Closed = true
+	}
+	if t.Title == "" {
+		t.Title = t.Description
+	}
+	return nil
+}
+
+// apiEmail is a received email as returned by /api/emails.
+type apiEmail struct {
+	ID      string
+	From    string
+	To      string
+	Subject string
+	Date    string
+	Parts   []apiEmailPart
+	FileIDs []string
+	Raw     map[string]any
+}
+
+// apiEmailPart is one body part of an email; Index is what /api/email/{id}/{index}
+// expects.
+type apiEmailPart struct {
+	Index       int    `json:"index"`
+	ContentType string `json:"content_type,omitempty"`
+	Name        string `json:"name,omitempty"`
+	FileID      string `json:"file_id,omitempty"`
+}
+
+// Received returns the parsed receive date, or the zero time when unknown.
+func (e apiEmail) Received() time.Time {
+	return parseAPITime(e.Date)
+}
+
+func (e *apiEmail) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	e.Raw = m
+	e.ID = stringField(m, "id", "emailId", "uuid")
+	e.From = addressField(m, true, "from", "sender", "fromAddress")
+	e.To = addressField(m, false, "emailAddress", "address", "recipient", "to", "toAddress")
+	e.Subject = stringField(m, "subject", "title")
+	e.Date = stringField(m, "receivedAt", "received", "date", "sentAt", "createdAt", "created")
+
+	for _, key := range []string{"bodies", "parts", "bodyParts", "contents", "attachments"} {
+		list, ok := m[key].([]any)
+		if !ok {
+			continue
+		}
+		for i, item := range list {
+			p := apiEmailPart{Index: i + 1}
+			switch v := item.(type) {
+			case string:
+				p.ContentType = v
+			case map[string]any:
+				if idx, ok := v["index"].(float64); ok && idx > 0 {
+					p.Index = int(idx)
+				}
+				p.ContentType = stringField(v, "contentType", "mimeType", "type")
+				p.Name = stringField(v, "name", "fileName", "filename")
+				p.FileID = stringField(v, "fileId", "documentId")
+			}
+			e.Parts = append(e.Parts, p)
+		}
+		break
+	}
+	if len(e.Parts) == 0 {
+		if n, ok := m["bodyCount"].(float64); ok {
+			for i := 1; i <= int(n); i++ {
+				e.Parts = append(e.Parts, apiEmailPart{Index: i})
+			}
+		}
+	}
+
+	seen := map[string]struct{}{}
+	addFile := func(id string) {
+		if _, ok := seen[id]; id != "" && !ok {
+			seen[id] = struct{}{}
+			e.FileIDs = append(e.FileIDs, id)
+		}
+	}
+	for _, p := range e.Parts {
+		addFile(p.FileID)
+	}
+	for _, key := range []string{"fileIds", "files", "documents"} {
+		list, _ := m[key].([]any)
+		for _, item := range list {
+			switch v := item.(type) {
+			case string:
+				addFile(v)
+			case map[string]any:
+				addFile(stringField(v, "id", "fileId"))
+			}
+		}
+	}
+	return nil
+}
+
+// addressField reads an address given as a string, an {address, name} object
+// or a list of either, returning the first. With display set the name is
+// included as "Name <address>".
+func addressField(m map[string]any, display bool, keys ...string) string {
+	for _, k := range keys {
+		v := m[k]
+		if list, ok := v.([]any); ok && len(list) > 0 {
+			v = list[0]
+		}
+		switch a := v.(type) {
+		case string:
+			if a != "" {
+				return a
+			}
+		case map[string]any:
+			addr := stringField(a, "address", "email", "emailAddress")
+			name := stringField(a, "name", "personal", "displayName")
+			if display && name != "" && addr != "" {
+				return name + " <" + addr + ">"
+			}
+			if addr != "" {
+				return addr
+			}
+		}
+	}
+	return ""
+}
+
+// apiEmailAddress is an inbound address as returned by /api/emailAddresses.
+type apiEmailAddress struct {
+	Address     string
+	Description string
+	CreatedAt   string
+	Raw         map[string]any
+}
+
+// Created returns the parsed creation time, or the zero time when unknown.
+func (a apiEmailAddress) Created() time.Time {
+	return parseAPITime(a.CreatedAt)
+}
+
+func (a *apiEmailAddress) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		a.Address = s
+		a.Raw = map[string]any{"address": s}
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	a.Raw = m
+	a.Address = stringField(m, "address", "emailAddress", "email")
+	a.Description = stringField(m, "description", "label", "name")
+	a.CreatedAt = stringField(m, "createdAt", "created", "createdDate")
+	return nil
+}
+
+// apiKey is an API key as returned by /api/user/apikeys. Key is only set in
+// the response to creating one; listings may carry a prefix or the last
+// characters instead.
+type apiKey struct {
+	ID        string
+	Name      string
+	Key       string
+	Prefix    string
+	Suffix    string
+	CreatedAt string
+	ExpiresAt string
+	Raw       map[string]any
+}
+
+// Matches reports whether key is this API key, as far as the listing shows.
+func (k apiKey) Matches(key string) bool {
+	switch {
+	case k.Key != "":
+		return k.Key == key
+	case k.Prefix != "" && k.Suffix != "":
+		return strings.HasPrefix(key, k.Prefix) && strings.HasSuffix(key, k.Suffix)
+	case k.Prefix != "":
+		return strings.HasPrefix(key, k.Prefix)
+	case k.Suffix != "":
+		return strings.HasSuffix(key, k.Suffix)
+	}
+	return false
+}
+
+func (k *apiKey) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	k.Raw = m
+	k.ID = stringField(m, "id", "keyId", "apiKeyId", "uuid")
+	k.Name = stringField(m, "name", "description", "label")
+	k.Key = stringField(m, "key", "apiKey", "token", "secret", "plainKey", "value")
+	k.Prefix = stringField(m, "prefix", "keyPrefix")
+	k.Suffix = stringField(m, "suffix", "lastFour", "last4", "keySuffix")
+	k.CreatedAt = stringField(m, "createdAt", "created", "createdDate")
+	k.ExpiresAt = stringField(m, "expiresAt", "expires", "expiryDate", "expirationDate")
+	return nil
+}
+
+// decodeItems accepts either a JSON array or an object wrapping the array in
+// one of the usual pagination fields.
+func decodeItems(b []byte, v any) error {
+	trimmed := strings.TrimSpace(string(b))
+	if strings.HasPrefix(trimmed, "{") {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(b, &wrapper); err != nil {
+			return err
+		}
+		for _, key := range []string{"items", "files", "tasks", "emails", "apiKeys", "keys", "content", "data", "results"} {
+			if raw, ok := wrapper[key]; ok && strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+				return json.Unmarshal(raw, v)
+			}
+		}
+		return fmt.Errorf("unexpected response: no list field found")
+	}
+	if trimmed == "" || trimmed == "null" {
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+func stringField(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// parseAPITime accepts RFC 3339 timestamps, timestamps without a zone and
+// plain dates.
+func parseAPITime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseLocalDay parses s like parseAPITime and returns its calendar day in
+// the local time zone as local midnight. Values without a zone are taken as
+// local dates.
+func parseLocalDay(s string) time.Time {
+	d := parseAPITime(s)
+	if d.IsZero() {
+		return d
+	}
+	if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		d = d.In(time.Local)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func boolField(m map[string]any, keys ...string) bool {
+	for _, k := range keys {
+		if v, ok := m[k].(bool); ok {
+			return v
+		}
+	}
+	return false
+}
+
+func getJSON(cmd *cobra.Command, path string, v any) error {
+	b, err := getBytes(cmd, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func getBytes(cmd *cobra.Command, path string) ([]byte, error) {
+	opts, err := resolveAPIConfig()
+	if err != nil {
+		return nil, err
+	}
+	if opts.APIKey == "" && opts.Token == "" {
+		return nil, fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+	}
+	return cli.GetBytes(cmd.Context(), path, opts)
+}
+
+// getOptionalBytes is getBytes for sub-resources a document may not have: a
+// 404 returns nil without an error.
+func getOptionalBytes(cmd *cobra.Command, path string) ([]byte, error) {
+	b, err := getBytes(cmd, path)
+	var httpErr *cli.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return b, err
+}
+
+// listAllFiles walks /api/user/files page by page until a short or empty
+// page. Pages are de-duplicated by ID so 0- and 1-based paging both work.
+func listAllFiles(cmd *cobra.Command, statuses []string, pageSize int) ([]apiFile, error) {
+	query := url.Values{}
+	for _, s := range statuses {
+		query.Add("status", s)
+	}
+	return listAllPages(cmd, "/api/user/files", query, pageSize, func(f apiFile) string { return f.ID })
+}
+
+// listAllPages requests path with pageSize/pageNumber until a short page, or
+// until two pages in a row add nothing new (for endpoints that ignore paging
+// or count pages from 1).
+func listAllPages[T any](cmd *cobra.Command, path string, query url.Values, pageSize int, id func(T) string) ([]T, error) {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	var all []T
+	seen := map[string]struct{}{}
+	stale := 0
+	for page := 0; ; page++ {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("pageSize", fmt.Sprintf("%d", pageSize))
+		q.Set("pageNumber", fmt.Sprintf("%d", page))
+		b, err := getBytes(cmd, path+"?"+q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var items []T
+		if err := decodeItems(b, &items); err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, item := range items {
+			if _, ok := seen[id(item)]; ok {
+				continue
+			}
+			seen[id(item)] = struct{}{}
+			all = append(all, item)
+			added++
+		}
+		if added == 0 {
+			stale++
+		} else {
+			stale = 0
+		}
+		if len(items) < pageSize || stale > 1 {
+			return all, nil
+		}
+	}
+}
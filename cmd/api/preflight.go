@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	preflightError = "error"
+	preflightWarn  = "warn"
+	preflightOff   = "off"
+)
+
+var (
+	uploadPreflight string
+	uploadMaxSizeMB int64
+)
+
+func validatePreflightMode() error {
+	switch uploadPreflight {
+	case preflightError, preflightWarn, preflightOff:
+		return nil
+	}
+	return fmt.Errorf("invalid --preflight %q; valid values: error, warn, off", uploadPreflight)
+}
+
+// preflightUpload runs the local checks on an upload. Problems are returned
+// as an error in error mode and printed to stderr in warn mode.
+func preflightUpload(name string, data []byte) error {
+	if uploadPreflight == preflightOff {
+		return nil
+	}
+	maxSize := viper.GetInt64("upload.max_size_mb") * 1024 * 1024
+	check := cli.CheckUpload(data, name, maxSize)
+	for _, w := range check.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", name, w)
+	}
+	if len(check.Errors) == 0 {
+		return nil
+	}
+	if uploadPreflight == preflightWarn {
+		for _, e := range check.Errors {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", name, e)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s rejected: %s", name, strings.Join(check.Errors, "; "))
+}
+
+func init() {
+	filesUploadCmd.Flags().StringVar(&uploadPreflight, "preflight", preflightError, "Local checks before upload: error (reject), warn, off")
+	filesUploadCmd.Flags().Int64Var(&uploadMaxSizeMB, "max-size-mb", 100, "Maximum upload size in MB (0 disables; default from upload.max_size_mb)")
+
+	viper.BindPFlag("upload.max_size_mb", filesUploadCmd.Flags().Lookup("max-size-mb"))
+}
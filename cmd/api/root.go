@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/zamedic/labradoc-cli/internal/cli"
@@ -48,11 +50,20 @@ func init() {
 
 }
 
-func resolveAPIConfig() (cli.RequestOptions, error) {
-	apiURL := apiURLFlag
-	if apiURL == "" {
-		apiURL = viper.GetString("api_url")
+// resolvedAPIURL prefers an explicit --api-url, then api_url from the
+// environment, the active profile or labrador.yaml, then the flag default.
+func resolvedAPIURL() string {
+	if RootCmd.PersistentFlags().Changed("api-url") {
+		return apiURLFlag
+	}
+	if v := viper.GetString("api_url"); v != "" {
+		return v
 	}
+	return apiURLFlag
+}
+
+func resolveAPIConfig() (cli.RequestOptions, error) {
+	apiURL := resolvedAPIURL()
 	if apiURL == "" {
 		return cli.RequestOptions{}, fmt.Errorf("missing api url (api_url)")
 	}
@@ -60,13 +71,27 @@ func resolveAPIConfig() (cli.RequestOptions, error) {
 	bearerToken := strings.TrimSpace(tokenFlag)
 	if apiToken == "" && bearerToken == "" {
 		if useAuthToken {
-			t, err := cli.LoadToken()
+			t, err := cli.LoadUsableToken(context.Background())
 			if err != nil {
 				return cli.RequestOptions{}, err
 			}
 			bearerToken = t.AccessToken
 		} else {
-			apiToken = strings.TrimSpace(viper.GetString("api_token"))
+			var err error
+			apiToken, err = cli.OpenSecret(strings.TrimSpace(viper.GetString("api_token")))
+			if err != nil {
+				return cli.RequestOptions{}, fmt.Errorf("api_token: %w", err)
+			}
+			// Without an API key, a service-account login is used as is.
+			if apiToken == "" {
+				t, err := serviceAccountToken()
+				if err != nil {
+					return cli.RequestOptions{}, err
+				}
+				if t != nil {
+					bearerToken = t.AccessToken
+				}
+			}
 		}
 	}
 	opts := cli.RequestOptions{
@@ -80,3 +105,38 @@ func resolveAPIConfig() (cli.RequestOptions, error) {
 	}
 	return opts, nil
 }
+
+var (
+	serviceTokenMu     sync.Mutex
+	serviceTokenLoaded bool
+	serviceToken       *cli.Token
+)
+
+// serviceAccountToken returns a usable token when the active profile is
+// logged in with client credentials, and nil otherwise. The token file is read
+// once per process; later calls reuse the token and only renew it when it
+// has expired.
+func serviceAccountToken() (*cli.Token, error) {
+	serviceTokenMu.Lock()
+	defer serviceTokenMu.Unlock()
+	if !serviceTokenLoaded {
+		serviceTokenLoaded = true
+		if cli.HasToken() {
+			if t, err := cli.LoadToken(); err == nil && t.Grant == cli.GrantClientCredentials {
+				serviceToken = t
+			}
+		}
+	}
+	if serviceToken == nil {
+		return nil, nil
+	}
+	t, err := cli.UsableToken(context.Background(), serviceToken)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the stored token's settings when the agent answered.
+	if t.Grant == cli.GrantClientCredentials {
+		serviceToken = t
+	}
+	return t, nil
+}
@@ -1,14 +1,14 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
-
-	"github.com/zamedic/labradoc-cli/internal/cli"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -18,90 +18,239 @@ var tasksCmd = &cobra.Command{
 	Short: "Task operations via the API",
 }
 
+var (
+	tasksFileID    string
+	tasksDueBefore string
+	tasksDueAfter  string
+	tasksSearch    string
+	tasksSort      string
+	tasksState     string
+	tasksOverdue   bool
+	tasksOutput    string
+)
+
 var tasksListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List tasks",
+	Long:  "Lists tasks from /api/tasks. Filters and sorting are applied locally; --output table shows the source document name.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		opts, err := resolveAPIConfig()
+		filter, err := resolveTaskFilter(tasksState)
 		if err != nil {
 			return err
 		}
-		if opts.APIKey == "" && opts.Token == "" {
-			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+		if tasksOutput != "json" && tasksOutput != "table" {
+			return fmt.Errorf("invalid --output %q; valid values: json, table", tasksOutput)
+		}
+		if filter.empty() && tasksSort == "" && tasksOutput == "json" {
+			return simpleGet(cmd, "/api/tasks", "")
 		}
-		resp, err := cli.DoRequest(cmd.Context(), "GET", "/api/tasks", nil, opts)
+		tasks, err := fetchTasks(cmd)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		tasks = filter.apply(tasks)
+		if err := sortTasks(tasks, tasksSort); err != nil {
 			return err
 		}
-		if resp.StatusCode >= 400 {
-			return fmt.Errorf("request failed: %s", resp.Status)
+
+		if tasksOutput == "table" {
+			resolveTaskFileNames(cmd, tasks)
+			return writeTaskTable(os.Stdout, tasks)
 		}
+		raw := make([]map[string]any, len(tasks))
+		for i, t := range tasks {
+			raw[i] = t.Raw
+		}
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, string(b))
 		return nil
 	},
 }
 
-var (
-	taskID   string
-	taskIDs  []string
-	tasksOut string
-)
+// taskFilter selects tasks locally; zero values match everything.
+type taskFilter struct {
+	FileID    string
+	DueBefore time.Time
+	DueAfter  time.Time
+	Search    string
+	State     string
+	Overdue   bool
+	Now       time.Time
+}
 
-var tasksCloseCmd = &cobra.Command{
-	Use:   "close",
-	Short: "Close tasks",
-	Long:  "Close multiple tasks or a single task when --id is provided.",
-	RunE: func(cmd *cobra.Command, _ []string) error {
-		opts, err := resolveAPIConfig()
-		if err != nil {
-			return err
+func resolveTaskFilter(state string) (taskFilter, error) {
+	f := taskFilter{
+		FileID:  strings.TrimSpace(tasksFileID),
+		Search:  strings.ToLower(strings.TrimSpace(tasksSearch)),
+		State:   state,
+		Overdue: tasksOverdue,
+		Now:     time.Now(),
+	}
+	switch f.State {
+	case "open", "closed", "all":
+	default:
+		return f, fmt.Errorf("invalid --state %q; valid values: open, closed, all", f.State)
+	}
+	if tasksDueBefore != "" {
+		if f.DueBefore = parseLocalDay(tasksDueBefore); f.DueBefore.IsZero() {
+			return f, fmt.Errorf("invalid --due-before %q (use YYYY-MM-DD or RFC 3339)", tasksDueBefore)
 		}
-		if opts.APIKey == "" && opts.Token == "" {
-			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+	}
+	if tasksDueAfter != "" {
+		if f.DueAfter = parseLocalDay(tasksDueAfter); f.DueAfter.IsZero() {
+			return f, fmt.Errorf("invalid --due-after %q (use YYYY-MM-DD or RFC 3339)", tasksDueAfter)
 		}
+	}
+	return f, nil
+}
 
-		if taskID != "" {
-			resp, err := cli.DoRequest(cmd.Context(), "POST", fmt.Sprintf("/api/tasks/%s/close", taskID), nil, opts)
-			if err != nil {
-				return err
-			}
-			defer resp.Body.Close()
-			return writeResponse(resp, tasksOut)
+func (f taskFilter) match(t apiTask) bool {
+	if f.FileID != "" && t.FileID != f.FileID {
+		return false
+	}
+	switch f.State {
+	case "open":
+		if t.Closed {
+			return false
+		}
+	case "closed":
+		if !t.Closed {
+			return false
+		}
+	}
+	// Calendar days in the local zone, like --overdue; both bounds are exclusive.
+	due := t.DueDay()
+	if !f.DueBefore.IsZero() && (due.IsZero() || !due.Before(f.DueBefore)) {
+		return false
+	}
+	if !f.DueAfter.IsZero() && (due.IsZero() || !due.After(f.DueAfter)) {
+		return false
+	}
+	if f.Overdue {
+		// Calendar days in the local zone: a task due today is not overdue.
+		day := t.DueDay()
+		today := time.Date(f.Now.Year(), f.Now.Month(), f.Now.Day(), 0, 0, 0, 0, f.Now.Location())
+		if t.Closed || day.IsZero() || !day.Before(today) {
+			return false
 		}
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(t.Title+"\n"+t.Description), f.Search) {
+		return false
+	}
+	return true
+}
 
-		ids := make([]string, 0, len(taskIDs))
-		for _, id := range taskIDs {
-			if strings.TrimSpace(id) != "" {
-				ids = append(ids, strings.TrimSpace(id))
-			}
+func (f taskFilter) empty() bool {
+	return f.FileID == "" && f.DueBefore.IsZero() && f.DueAfter.IsZero() && f.Search == "" &&
+		(f.State == "" || f.State == "all") && !f.Overdue
+}
+
+func (f taskFilter) apply(tasks []apiTask) []apiTask {
+	out := tasks[:0:0]
+	for _, t := range tasks {
+		if f.match(t) {
+			out = append(out, t)
 		}
-		if len(ids) == 0 {
-			return fmt.Errorf("missing --id or --ids")
+	}
+	return out
+}
+
+func fetchTasks(cmd *cobra.Command) ([]apiTask, error) {
+	b, err := getBytes(cmd, "/api/tasks")
+	if err != nil {
+		return nil, err
+	}
+	var tasks []apiTask
+	if err := decodeItems(b, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// sortTasks orders by due or created date; tasks without a date go last.
+func sortTasks(tasks []apiTask, by string) error {
+	var key func(apiTask) time.Time
+	switch by {
+	case "":
+		return nil
+	case "due":
+		key = apiTask.Due
+	case "created":
+		key = apiTask.Created
+	default:
+		return fmt.Errorf("invalid --sort %q; valid values: due, created", by)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := key(tasks[i]), key(tasks[j])
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
 		}
-		body, err := json.Marshal(map[string]any{"id": ids})
-		if err != nil {
-			return err
+		return a.Before(b)
+	})
+	return nil
+}
+
+// resolveTaskFileNames fills in missing document names with one lookup per
+// file. Lookup failures leave the name empty.
+func resolveTaskFileNames(cmd *cobra.Command, tasks []apiTask) {
+	names := map[string]string{}
+	for i, t := range tasks {
+		if t.FileName != "" || t.FileID == "" {
+			continue
 		}
-		opts.Headers = map[string]string{
-			"Content-Type": "application/json",
+		name, ok := names[t.FileID]
+		if !ok {
+			var f apiFile
+			if err := getJSON(cmd, fmt.Sprintf("/api/user/files/%s", t.FileID), &f); err == nil {
+				name = f.Name
+			}
+			names[t.FileID] = name
 		}
-		resp, err := cli.DoRequest(cmd.Context(), "POST", "/api/tasks/close", bytes.NewReader(body), opts)
-		if err != nil {
-			return err
+		tasks[i].FileName = name
+	}
+}
+
+func writeTaskTable(w io.Writer, tasks []apiTask) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDUE\tSTATE\tTITLE\tDOCUMENT")
+	for _, t := range tasks {
+		due := "-"
+		if d := t.DueDay(); !d.IsZero() {
+			due = d.Format(time.DateOnly)
 		}
-		defer resp.Body.Close()
-		return writeResponse(resp, tasksOut)
-	},
+		state := "open"
+		if t.Closed {
+			state = "closed"
+		}
+		doc := t.FileName
+		if doc == "" {
+			doc = t.FileID
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.ID, due, state, truncate(t.Title, 60), doc)
+	}
+	return tw.Flush()
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
 }
 
 func init() {
 	tasksCmd.AddCommand(tasksListCmd)
-	tasksCmd.AddCommand(tasksCloseCmd)
 
-	tasksCloseCmd.Flags().StringVar(&taskID, "id", "", "Task ID to close")
-	tasksCloseCmd.Flags().StringSliceVar(&taskIDs, "ids", nil, "Task IDs to close (repeatable)")
-	tasksCloseCmd.Flags().StringVar(&tasksOut, "out", "", "Write response to file instead of stdout")
+	tasksListCmd.Flags().StringVar(&tasksFileID, "file-id", "", "Only tasks for this document")
+	tasksListCmd.Flags().StringVar(&tasksDueBefore, "due-before", "", "Only tasks due before this day, exclusive (YYYY-MM-DD or RFC 3339)")
+	tasksListCmd.Flags().StringVar(&tasksDueAfter, "due-after", "", "Only tasks due after this day, exclusive (YYYY-MM-DD or RFC 3339)")
+	tasksListCmd.Flags().StringVar(&tasksSearch, "search", "", "Only tasks whose title or description contains this text")
+	tasksListCmd.Flags().StringVar(&tasksState, "state", "all", "Task state: open, closed, all")
+	tasksListCmd.Flags().BoolVar(&tasksOverdue, "overdue", false, "Only open tasks that are past their due date")
+	tasksListCmd.Flags().StringVar(&tasksSort, "sort", "", "Sort by: due, created")
+	tasksListCmd.Flags().StringVar(&tasksOutput, "output", "json", "Output format: json, table")
 }
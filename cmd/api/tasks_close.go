@@ -0,0 +1,467 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	taskID            string
+	taskIDs           []string
+	tasksOut          string
+	closeIDsFrom      string
+	closeArchivedDocs bool
+	closeInteractive  bool
+	closeDryRun       bool
+	closeYes          bool
+	closeChunkSize    int
+)
+
+var tasksCloseCmd = &cobra.Command{
+	Use:   "close",
+	Short: "Close tasks",
+	Long: "Close tasks given with --id/--ids, read from --ids-from (a file or - for stdin), or selected from open tasks " +
+		"with the tasks list filters, --archived-documents or the --interactive picker. Selections other than --id/--ids " +
+		"ask for confirmation unless --yes is given. Batches are posted to /api/tasks/close in chunks of --chunk-size.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts, err := resolveAPIConfig()
+		if err != nil {
+			return err
+		}
+		if opts.APIKey == "" && opts.Token == "" {
+			return fmt.Errorf("missing api token (use --api-token, --token, api_token, or --use-auth-token)")
+		}
+		if closeChunkSize <= 0 {
+			return fmt.Errorf("--chunk-size must be positive")
+		}
+
+		explicit := make([]string, 0, len(taskIDs)+1)
+		if taskID != "" {
+			explicit = append(explicit, taskID)
+		}
+		explicit = append(explicit, taskIDs...)
+		var fromFile []string
+		if closeIDsFrom != "" {
+			if fromFile, err = readTaskIDs(closeIDsFrom); err != nil {
+				return err
+			}
+		}
+		selecting := closeSelectorsSet(cmd)
+		if selecting && (len(explicit) > 0 || closeIDsFrom != "") {
+			return fmt.Errorf("use either task IDs (--id, --ids, --ids-from) or selectors, not both")
+		}
+		if closeInteractive && closeIDsFrom == "-" {
+			return fmt.Errorf("--interactive cannot be combined with --ids-from -")
+		}
+
+		// A single --id keeps using the per-task endpoint.
+		if taskID != "" && len(taskIDs) == 0 && closeIDsFrom == "" {
+			if closeDryRun {
+				fmt.Fprintf(os.Stdout, "would close %s\n", taskID)
+				return nil
+			}
+			resp, err := cli.DoRequest(cmd.Context(), "POST", fmt.Sprintf("/api/tasks/%s/close", taskID), nil, opts)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return writeResponse(resp, tasksOut)
+		}
+
+		var (
+			tasks   []apiTask
+			confirm = closeIDsFrom != ""
+		)
+		if selecting {
+			tasks, err = selectTasksToClose(cmd)
+			if errors.Is(err, errNothingSelected) {
+				fmt.Fprintln(os.Stderr, "Nothing selected.")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			confirm = true
+		} else {
+			for _, id := range dedupeIDs(append(explicit, fromFile...)) {
+				tasks = append(tasks, apiTask{ID: id})
+			}
+			if len(tasks) == 0 {
+				return fmt.Errorf("missing --id, --ids, --ids-from or a selector")
+			}
+		}
+		if len(tasks) == 0 {
+			fmt.Fprintln(os.Stderr, "No matching open tasks.")
+			return nil
+		}
+
+		if closeDryRun {
+			if selecting {
+				return writeTaskTable(os.Stdout, tasks)
+			}
+			for _, t := range tasks {
+				fmt.Fprintf(os.Stdout, "would close %s\n", t.ID)
+			}
+			return nil
+		}
+		if confirm && !closeYes {
+			if !stdinIsTerminal() || closeIDsFrom == "-" {
+				return fmt.Errorf("refusing to close %d tasks without confirmation; pass --yes", len(tasks))
+			}
+			if !selecting {
+				for _, t := range tasks {
+					fmt.Fprintln(os.Stderr, t.ID)
+				}
+			} else if !closeInteractive {
+				if err := writeTaskTable(os.Stderr, tasks); err != nil {
+					return err
+				}
+			}
+			ok, err := promptYesNo(fmt.Sprintf("Close %d tasks?", len(tasks)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(os.Stderr, "Cancelled.")
+				return nil
+			}
+		}
+
+		ids := make([]string, len(tasks))
+		for i, t := range tasks {
+			ids[i] = t.ID
+		}
+		out, err := closeTasksChunked(cmd, opts, ids, closeChunkSize)
+		if err != nil {
+			return err
+		}
+		return writeOutput(out, tasksOut)
+	},
+}
+
+// closeSelectorsSet reports whether tasks should be picked from /api/tasks
+// instead of being named explicitly.
+func closeSelectorsSet(cmd *cobra.Command) bool {
+	for _, name := range []string{"file-id", "due-before", "due-after", "search", "overdue"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return closeArchivedDocs || closeInteractive
+}
+
+func selectTasksToClose(cmd *cobra.Command) ([]apiTask, error) {
+	filter, err := resolveTaskFilter("open")
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := fetchTasks(cmd)
+	if err != nil {
+		return nil, err
+	}
+	tasks = filter.apply(tasks)
+	if closeArchivedDocs {
+		if tasks, err = tasksForArchivedDocuments(cmd, tasks); err != nil {
+			return nil, err
+		}
+	}
+	_ = sortTasks(tasks, "due")
+	resolveTaskFileNames(cmd, tasks)
+	if closeInteractive && len(tasks) > 0 {
+		return pickTasks(tasks)
+	}
+	return tasks, nil
+}
+
+// tasksForArchivedDocuments keeps tasks whose document is reported as
+// archived. A 404 on the file says nothing about its archived state, so those
+// tasks are skipped and listed on stderr.
+func tasksForArchivedDocuments(cmd *cobra.Command, tasks []apiTask) ([]apiTask, error) {
+	type fileState struct{ archived, known bool }
+	files := map[string]fileState{}
+	out := tasks[:0:0]
+	for _, t := range tasks {
+		if t.FileID == "" {
+			continue
+		}
+		st, ok := files[t.FileID]
+		if !ok {
+			var f apiFile
+			err := getJSON(cmd, fmt.Sprintf("/api/user/files/%s", t.FileID), &f)
+			var httpErr *cli.HTTPError
+			switch {
+			case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+			case err != nil:
+				return nil, fmt.Errorf("look up file %s: %w", t.FileID, err)
+			default:
+				st = fileState{archived: f.Archived, known: true}
+			}
+			files[t.FileID] = st
+		}
+		if !st.known {
+			fmt.Fprintf(os.Stderr, "skipping task %s: document %s not found, archived state unknown\n", t.ID, t.FileID)
+			continue
+		}
+		if st.archived {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// closeTasksChunked posts the IDs to /api/tasks/close in chunks and returns
+// the response bodies one per line.
+func closeTasksChunked(cmd *cobra.Command, opts cli.RequestOptions, ids []string, chunkSize int) ([]byte, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("--chunk-size must be positive")
+	}
+	opts.Headers = map[string]string{
+		"Content-Type": "application/json",
+	}
+	var out bytes.Buffer
+	closed := 0
+	for start := 0; start < len(ids); start += chunkSize {
+		chunk := ids[start:min(start+chunkSize, len(ids))]
+		body, err := json.Marshal(map[string]any{"id": chunk})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := cli.DoRequest(cmd.Context(), "POST", "/api/tasks/close", bytes.NewReader(body), opts)
+		if err != nil {
+			return nil, fmt.Errorf("closed %d of %d tasks: %w", closed, len(ids), err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("closed %d of %d tasks: request failed: %s: %s", closed, len(ids), resp.Status, strings.TrimSpace(string(b)))
+		}
+		closed += len(chunk)
+		if len(ids) > chunkSize {
+			fmt.Fprintf(os.Stderr, "Closed %d/%d tasks\n", closed, len(ids))
+		}
+		out.Write(b)
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// readTaskIDs reads IDs from a file or stdin ("-"). It accepts one ID per line
+// (blank lines and # comments ignored) or a JSON array of IDs or task objects,
+// such as the output of tasks list.
+func readTaskIDs(path string) ([]string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(b); bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		var items []json.RawMessage
+		if err := decodeItems(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		ids := make([]string, 0, len(items))
+		for _, raw := range items {
+			var id string
+			if err := json.Unmarshal(raw, &id); err == nil {
+				ids = append(ids, id)
+				continue
+			}
+			var t apiTask
+			if err := json.Unmarshal(raw, &t); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+			ids = append(ids, t.ID)
+		}
+		return dedupeIDs(ids), nil
+	}
+	var ids []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, strings.Fields(strings.ReplaceAll(line, ",", " "))...)
+	}
+	return dedupeIDs(ids), nil
+}
+
+func dedupeIDs(in []string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(in))
+	for _, id := range in {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func promptLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func promptYesNo(question string) (bool, error) {
+	answer, err := promptLine(question + " [y/N] ")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// errNothingSelected is returned by pickTasks when the user quits or confirms
+// an empty selection.
+var errNothingSelected = errors.New("nothing selected")
+
+// pickTasks shows a numbered list and lets the user toggle entries by number
+// or range until they confirm the selection.
+func pickTasks(tasks []apiTask) ([]apiTask, error) {
+	if !stdinIsTerminal() {
+		return nil, fmt.Errorf("--interactive needs a terminal")
+	}
+	selected := make([]bool, len(tasks))
+	for {
+		count := 0
+		for i, t := range tasks {
+			mark := " "
+			if selected[i] {
+				mark = "x"
+				count++
+			}
+			due := "          "
+			if d := t.DueDay(); !d.IsZero() {
+				due = d.Format(time.DateOnly)
+			}
+			fmt.Fprintf(os.Stderr, "%3d [%s] %s  %s", i+1, mark, due, truncate(t.Title, 60))
+			if t.FileName != "" {
+				fmt.Fprintf(os.Stderr, "  (%s)", truncate(t.FileName, 40))
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		answer, err := promptLine(fmt.Sprintf("%d selected. Toggle numbers or ranges (1,3,5-7), a = all, n = none, enter = done, q = quit: ", count))
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			out := tasks[:0:0]
+			for i, t := range tasks {
+				if selected[i] {
+					out = append(out, t)
+				}
+			}
+			if len(out) == 0 {
+				return nil, errNothingSelected
+			}
+			return out, nil
+		case "q":
+			return nil, errNothingSelected
+		case "a":
+			for i := range selected {
+				selected[i] = true
+			}
+			continue
+		case "n":
+			clear(selected)
+			continue
+		}
+		picks, err := parseSelection(answer, len(tasks))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		for _, i := range picks {
+			selected[i] = !selected[i]
+		}
+	}
+}
+
+// parseSelection turns "1,3,5-7" into zero-based indexes below n.
+func parseSelection(s string, n int) ([]int, error) {
+	var out []int
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid selection %q", part)
+			}
+		}
+		if a < 1 || b > n || a > b {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", part, n)
+		}
+		for i := a; i <= b; i++ {
+			out = append(out, i-1)
+		}
+	}
+	return out, nil
+}
+
+func init() {
+	tasksCmd.AddCommand(tasksCloseCmd)
+
+	tasksCloseCmd.Flags().StringVar(&taskID, "id", "", "Task ID to close")
+	tasksCloseCmd.Flags().StringSliceVar(&taskIDs, "ids", nil, "Task IDs to close (repeatable)")
+	tasksCloseCmd.Flags().StringVar(&closeIDsFrom, "ids-from", "", "Read task IDs from a file, or - for stdin (one per line or a JSON array)")
+	tasksCloseCmd.Flags().StringVar(&tasksFileID, "file-id", "", "Close open tasks for this document")
+	tasksCloseCmd.Flags().StringVar(&tasksDueBefore, "due-before", "", "Close open tasks due before this day, exclusive (YYYY-MM-DD or RFC 3339)")
+	tasksCloseCmd.Flags().StringVar(&tasksDueAfter, "due-after", "", "Close open tasks due after this day, exclusive (YYYY-MM-DD or RFC 3339)")
+	tasksCloseCmd.Flags().StringVar(&tasksSearch, "search", "", "Close open tasks whose title or description contains this text")
+	tasksCloseCmd.Flags().BoolVar(&tasksOverdue, "overdue", false, "Close open tasks that are past their due date")
+	tasksCloseCmd.Flags().BoolVar(&closeArchivedDocs, "archived-documents", false, "Close open tasks whose document is archived")
+	tasksCloseCmd.Flags().BoolVar(&closeInteractive, "interactive", false, "Pick tasks to close from a list in the terminal")
+	tasksCloseCmd.Flags().BoolVar(&closeDryRun, "dry-run", false, "Show which tasks would be closed without closing them")
+	tasksCloseCmd.Flags().BoolVarP(&closeYes, "yes", "y", false, "Do not ask for confirmation")
+	tasksCloseCmd.Flags().IntVar(&closeChunkSize, "chunk-size", 100, "Maximum task IDs per /api/tasks/close request")
+	tasksCloseCmd.Flags().StringVar(&tasksOut, "out", "", "Write response to file instead of stdout")
+}
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	taskExportICS      = "ics"
+	taskExportMarkdown = "markdown"
+	taskExportTodoTxt  = "todotxt"
+
+	icsComponentTodo  = "todo"
+	icsComponentEvent = "event"
+
+	defaultTaskLinkTemplate = "{api_url}/api/user/files/{file_id}/download"
+)
+
+var (
+	tasksExportFormat string
+	tasksExportState  string
+	tasksExportSort   string
+	tasksExportOut    string
+	icsComponent      string
+	icsLinkTemplate   string
+	icsListen         string
+	icsRefresh        time.Duration
+)
+
+var tasksExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export tasks to other formats",
+	Long: "Exports tasks from /api/tasks. The ics format writes an iCalendar feed with one VTODO (or VEVENT with --component event) per task, using stable UIDs derived from the task IDs. " +
+		"The markdown and todotxt formats write checklists grouped by source document that tasks reconcile can read back.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		filter, err := resolveTaskFilter(tasksExportState)
+		if err != nil {
+			return err
+		}
+		tasks, err := fetchTasks(cmd)
+		if err != nil {
+			return err
+		}
+		tasks = filter.apply(tasks)
+		if err := sortTasks(tasks, tasksExportSort); err != nil {
+			return err
+		}
+		resolveTaskFileNames(cmd, tasks)
+
+		var out []byte
+		switch tasksExportFormat {
+		case taskExportICS:
+			out, err = buildTasksICS(tasks, time.Now())
+		case taskExportMarkdown:
+			out = buildTasksMarkdown(groupTasksByDocument(tasks))
+		case taskExportTodoTxt:
+			out = buildTasksTodoTxt(groupTasksByDocument(tasks))
+		default:
+			err = fmt.Errorf("invalid --format %q; valid values: %s, %s, %s", tasksExportFormat, taskExportICS, taskExportMarkdown, taskExportTodoTxt)
+		}
+		if err != nil {
+			return err
+		}
+		return writeOutput(out, tasksExportOut)
+	},
+}
+
+var tasksServeICSCmd = &cobra.Command{
+	Use:   "serve-ics",
+	Short: "Serve tasks as an iCalendar feed over HTTP",
+	Long:  "Runs a local HTTP endpoint that calendar apps can subscribe to. The feed is served at / and /tasks.ics and refreshed from /api/tasks every --refresh interval.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if icsRefresh <= 0 {
+			return fmt.Errorf("--refresh must be positive")
+		}
+		filter, err := resolveTaskFilter(tasksExportState)
+		if err != nil {
+			return err
+		}
+		if _, err := buildTasksICS(nil, time.Now()); err != nil {
+			return err
+		}
+		if err := sortTasks(nil, tasksExportSort); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		cmd.SetContext(ctx)
+
+		var (
+			mu      sync.RWMutex
+			feed    []byte
+			updated time.Time
+		)
+		refresh := func() {
+			tasks, err := fetchTasks(cmd)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "refresh failed: %v\n", err)
+				return
+			}
+			tasks = filter.apply(tasks)
+			_ = sortTasks(tasks, tasksExportSort)
+			resolveTaskFileNames(cmd, tasks)
+			b, err := buildTasksICS(tasks, time.Now())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "refresh failed: %v\n", err)
+				return
+			}
+			mu.Lock()
+			feed, updated = b, time.Now()
+			mu.Unlock()
+		}
+		refresh()
+
+		mux := http.NewServeMux()
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			mu.RLock()
+			b, at := feed, updated
+			mu.RUnlock()
+			if b == nil {
+				http.Error(w, "feed not available yet", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+			_, _ = w.Write(b)
+		}
+		mux.HandleFunc("/", handler)
+		mux.HandleFunc("/tasks.ics", handler)
+		server := &http.Server{Addr: icsListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			ticker := time.NewTicker(icsRefresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					_ = server.Shutdown(context.Background())
+					return
+				case <-ticker.C:
+					refresh()
+				}
+			}
+		}()
+
+		fmt.Fprintf(os.Stderr, "Serving tasks feed on http://%s/tasks.ics (refresh every %s)\n", displayAddr(icsListen), icsRefresh)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// buildTasksICS renders tasks as an iCalendar document. VEVENT output skips
+// tasks without a due date since events need a start.
+func buildTasksICS(tasks []apiTask, now time.Time) ([]byte, error) {
+	var component string
+	switch icsComponent {
+	case icsComponentTodo:
+		component = "VTODO"
+	case icsComponentEvent:
+		component = "VEVENT"
+	default:
+		return nil, fmt.Errorf("invalid --component %q; valid values: todo, event", icsComponent)
+	}
+	apiURL := exportAPIURL()
+	host := "labradoc"
+	if u, err := url.Parse(apiURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	var buf bytes.Buffer
+	w := icsWriter{w: &buf}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Labradoc//labradoc-cli//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("X-WR-CALNAME:Labradoc tasks")
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, t := range tasks {
+		due := t.Due()
+		if component == "VEVENT" && due.IsZero() {
+			continue
+		}
+		w.line("BEGIN:" + component)
+		w.line("UID:task-" + t.ID + "@" + host)
+		w.line("DTSTAMP:" + stamp)
+		w.line("SUMMARY:" + icsEscape(t.Title))
+		if t.Description != "" && t.Description != t.Title {
+			w.line("DESCRIPTION:" + icsEscape(t.Description))
+		}
+		if created := t.Created(); !created.IsZero() {
+			w.line("CREATED:" + created.UTC().Format("20060102T150405Z"))
+		}
+		if !due.IsZero() {
+			dateOnly := len(t.DueDate) == len(time.DateOnly)
+			switch {
+			case component == "VTODO" && dateOnly:
+				w.line("DUE;VALUE=DATE:" + due.Format("20060102"))
+			case component == "VTODO":
+				w.line("DUE:" + due.UTC().Format("20060102T150405Z"))
+			default:
+				day := t.DueDay()
+				w.line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+				w.line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+				w.line("TRANSP:TRANSPARENT")
+			}
+		}
+		if component == "VTODO" {
+			if t.Closed {
+				w.line("STATUS:COMPLETED")
+			} else {
+				w.line("STATUS:NEEDS-ACTION")
+			}
+		}
+		if t.FileID != "" {
+			w.line("URL:" + taskDocumentLink(apiURL, t))
+			if t.FileName != "" {
+				w.line("X-LABRADOC-DOCUMENT:" + icsEscape(t.FileName))
+			}
+		}
+		w.line("END:" + component)
+	}
+	w.line("END:VCALENDAR")
+	return buf.Bytes(), w.err
+}
+
+func exportAPIURL() string {
+	return strings.TrimRight(resolvedAPIURL(), "/")
+}
+
+func taskDocumentLink(apiURL string, t apiTask) string {
+	return strings.NewReplacer("{api_url}", apiURL, "{file_id}", url.PathEscape(t.FileID), "{task_id}", url.PathEscape(t.ID)).Replace(icsLinkTemplate)
+}
+
+// taskGroup is the tasks of one source document; FileID is empty for tasks
+// without a document.
+type taskGroup struct {
+	FileID string
+	Name   string
+	Tasks  []apiTask
+}
+
+// groupTasksByDocument keeps the task order within each group and orders
+// groups by their first task. Tasks without a document go last.
+func groupTasksByDocument(tasks []apiTask) []taskGroup {
+	var groups []taskGroup
+	index := map[string]int{}
+	var loose []apiTask
+	for _, t := range tasks {
+		if t.FileID == "" {
+			loose = append(loose, t)
+			continue
+		}
+		i, ok := index[t.FileID]
+		if !ok {
+			name := t.FileName
+			if name == "" {
+				name = t.FileID
+			}
+			i = len(groups)
+			index[t.FileID] = i
+			groups = append(groups, taskGroup{FileID: t.FileID, Name: name})
+		}
+		groups[i].Tasks = append(groups[i].Tasks, t)
+	}
+	if len(loose) > 0 {
+		groups = append(groups, taskGroup{Name: "No document", Tasks: loose})
+	}
+	return groups
+}
+
+// buildTasksMarkdown writes one section per document with a checklist item per
+// task. The task ID sits in an HTML comment so tasks reconcile can match
+// checked items without cluttering the rendered list.
+func buildTasksMarkdown(groups []taskGroup) []byte {
+	apiURL := exportAPIURL()
+	var b strings.Builder
+	b.WriteString("# Labradoc tasks\n")
+	for _, g := range groups {
+		b.WriteString("\n## ")
+		if g.FileID != "" {
+			fmt.Fprintf(&b, "[%s](%s)", markdownEscape(g.Name), taskDocumentLink(apiURL, g.Tasks[0]))
+		} else {
+			b.WriteString(markdownEscape(g.Name))
+		}
+		b.WriteString("\n\n")
+		for _, t := range g.Tasks {
+			box := " "
+			if t.Closed {
+				box = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s", box, markdownEscape(strings.Join(strings.Fields(t.Title), " ")))
+			if due := t.DueDay(); !due.IsZero() {
+				fmt.Fprintf(&b, " (due %s)", due.Format(time.DateOnly))
+			}
+			fmt.Fprintf(&b, " <!-- %s%s -->\n", markdownTaskMarker, t.ID)
+		}
+	}
+	return []byte(b.String())
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "<", `\<`, "`", "\\`").Replace(s)
+}
+
+// buildTasksTodoTxt writes one todo.txt line per task, grouped by document
+// through a +project tag. Closed tasks are prefixed with "x"; open tasks carry
+// their creation date.
+func buildTasksTodoTxt(groups []taskGroup) []byte {
+	var b strings.Builder
+	for _, g := range groups {
+		project := ""
+		if g.FileID != "" {
+			project = "+" + todoTxtTag(g.Name)
+		}
+		for _, t := range g.Tasks {
+			// The API has no completion date and the first date after "x" is
+			// read as one, so the creation date is only written for open tasks.
+			var parts []string
+			if t.Closed {
+				parts = append(parts, "x")
+			} else if created := t.Created(); !created.IsZero() {
+				parts = append(parts, created.Format(time.DateOnly))
+			}
+			parts = append(parts, strings.Join(strings.Fields(t.Title), " "))
+			if project != "" {
+				parts = append(parts, project)
+			}
+			if due := t.DueDay(); !due.IsZero() {
+				parts = append(parts, "due:"+due.Format(time.DateOnly))
+			}
+			parts = append(parts, todoTxtIDKey+":"+t.ID)
+			line := strings.Join(parts, " ")
+			if !t.Closed && strings.HasPrefix(line, "x ") {
+				// An undated open task titled "x ..." would read as completed
+				// and be closed by tasks reconcile.
+				line = "X" + line[1:]
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// todoTxtTag turns a document name into a single-word project tag.
+func todoTxtTag(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	tag := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	tag = strings.Trim(tag, "_")
+	if tag == "" {
+		return "document"
+	}
+	return tag
+}
+
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsWriter writes CRLF-terminated content lines folded at 75 octets as
+// required by RFC 5545.
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *icsWriter) line(s string) {
+	if w.err != nil {
+		return
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	_, w.err = io.WriteString(w.w, b.String())
+}
+
+func init() {
+	tasksCmd.AddCommand(tasksExportCmd)
+	tasksCmd.AddCommand(tasksServeICSCmd)
+
+	for _, c := range []*cobra.Command{tasksExportCmd, tasksServeICSCmd} {
+		c.Flags().StringVar(&tasksFileID, "file-id", "", "Only tasks for this document")
+		c.Flags().StringVar(&tasksDueBefore, "due-before", "", "Only tasks due before this day, exclusive (YYYY-MM-DD or RFC 3339)")
+		c.Flags().StringVar(&tasksDueAfter, "due-after", "", "Only tasks due after this day, exclusive (YYYY-MM-DD or RFC 3339)")
+		c.Flags().StringVar(&tasksSearch, "search", "", "Only tasks whose title or description contains this text")
+		c.Flags().StringVar(&tasksExportState, "state", "open", "Task state: open, closed, all")
+		c.Flags().BoolVar(&tasksOverdue, "overdue", false, "Only open tasks that are past their due date")
+		c.Flags().StringVar(&tasksExportSort, "sort", "due", "Sort by: due, created")
+		c.Flags().StringVar(&icsComponent, "component", icsComponentTodo, "iCalendar component: todo (VTODO), event (VEVENT, all-day on the due date)")
+		c.Flags().StringVar(&icsLinkTemplate, "link-template", defaultTaskLinkTemplate, "Link to the source document; {api_url}, {file_id} and {task_id} are replaced")
+	}
+	tasksExportCmd.Flags().StringVar(&tasksExportFormat, "format", taskExportICS, "Export format: "+taskExportICS+", "+taskExportMarkdown+", "+taskExportTodoTxt)
+	tasksExportCmd.Flags().StringVar(&tasksExportOut, "out", "", "Write export to file instead of stdout")
+	tasksServeICSCmd.Flags().StringVar(&icsListen, "listen", "127.0.0.1:8088", "Address to listen on")
+	tasksServeICSCmd.Flags().DurationVar(&icsRefresh, "refresh", 5*time.Minute, "How often to refresh tasks from the API")
+}
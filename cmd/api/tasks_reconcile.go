@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	markdownTaskMarker = "labradoc:task:"
+	todoTxtIDKey       = "labradoc"
+)
+
+var (
+	reconcileFormat string
+	reconcileDryRun bool
+	reconcileOut    string
+)
+
+var (
+	markdownCheckedRe = regexp.MustCompile(`^\s*[-*+]\s+\[[xX]\]\s`)
+	markdownIDRe      = regexp.MustCompile(`<!--\s*` + regexp.QuoteMeta(markdownTaskMarker) + `(\S+)\s*-->`)
+	todoTxtIDRe       = regexp.MustCompile(`(?:^|\s)` + regexp.QuoteMeta(todoTxtIDKey) + `:(\S+)\s*$`)
+)
+
+var tasksReconcileCmd = &cobra.Command{
+	Use:   "reconcile <file>",
+	Short: "Close tasks checked off in an exported file",
+	Long: "Reads a file written by tasks export --format markdown or todotxt and closes, through /api/tasks/close, " +
+		"the tasks that have been checked off (- [x] in Markdown, a leading x in todo.txt) but are still open in Labradoc.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if closeChunkSize <= 0 {
+			return fmt.Errorf("--chunk-size must be positive")
+		}
+		path := args[0]
+		format := reconcileFormat
+		if format == "" {
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".md", ".markdown":
+				format = taskExportMarkdown
+			default:
+				format = taskExportTodoTxt
+			}
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var checked []string
+		switch format {
+		case taskExportMarkdown:
+			checked = checkedMarkdownTasks(string(b))
+		case taskExportTodoTxt:
+			checked = checkedTodoTxtTasks(string(b))
+		default:
+			return fmt.Errorf("invalid --format %q; valid values: %s, %s", format, taskExportMarkdown, taskExportTodoTxt)
+		}
+		if len(checked) == 0 {
+			fmt.Fprintln(os.Stderr, "No checked-off tasks found.")
+			return nil
+		}
+
+		tasks, err := fetchTasks(cmd)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]apiTask, len(tasks))
+		for _, t := range tasks {
+			byID[t.ID] = t
+		}
+		var ids []string
+		var alreadyClosed, unknown int
+		for _, id := range checked {
+			t, ok := byID[id]
+			switch {
+			case !ok:
+				unknown++
+			case t.Closed:
+				alreadyClosed++
+			default:
+				ids = append(ids, id)
+				fmt.Fprintf(os.Stderr, "close %s\t%s\n", id, truncate(t.Title, 60))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d checked off: %d to close, %d already closed, %d not found\n", len(checked), len(ids), alreadyClosed, unknown)
+		if reconcileDryRun || len(ids) == 0 {
+			return nil
+		}
+
+		opts, err := resolveAPIConfig()
+		if err != nil {
+			return err
+		}
+		out, err := closeTasksChunked(cmd, opts, ids, closeChunkSize)
+		if err != nil {
+			return err
+		}
+		return writeOutput(out, reconcileOut)
+	},
+}
+
+// checkedMarkdownTasks returns the IDs of checked checklist items that carry
+// a task marker comment.
+func checkedMarkdownTasks(s string) []string {
+	var ids []string
+	for _, line := range strings.Split(s, "\n") {
+		if !markdownCheckedRe.MatchString(line) {
+			continue
+		}
+		if m := markdownIDRe.FindStringSubmatch(line); m != nil {
+			ids = append(ids, m[1])
+		}
+	}
+	return dedupeIDs(ids)
+}
+
+// checkedTodoTxtTasks returns the IDs of completed todo.txt lines. Only the
+// trailing labradoc:<id> token counts, so one inside the title is ignored.
+func checkedTodoTxtTasks(s string) []string {
+	var ids []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "x ") {
+			continue
+		}
+		if m := todoTxtIDRe.FindStringSubmatch(line); m != nil {
+			ids = append(ids, m[1])
+		}
+	}
+	return dedupeIDs(ids)
+}
+
+func init() {
+	tasksCmd.AddCommand(tasksReconcileCmd)
+
+	tasksReconcileCmd.Flags().StringVar(&reconcileFormat, "format", "", "File format: markdown, todotxt (default from the file extension)")
+	tasksReconcileCmd.Flags().BoolVar(&reconcileDryRun, "dry-run", false, "Show which tasks would be closed without closing them")
+	tasksReconcileCmd.Flags().IntVar(&closeChunkSize, "chunk-size", 100, "Maximum task IDs per /api/tasks/close request")
+	tasksReconcileCmd.Flags().StringVar(&reconcileOut, "out", "", "Write response to file instead of stdout")
+}
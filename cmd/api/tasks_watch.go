@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	taskEventCreated = "task.created"
+	taskEventClosed  = "task.closed"
+
+	tasksWatchStateFile = "tasks-watch.json"
+
+	watchSinkExec    = "exec"
+	watchSinkWebhook = "webhook"
+)
+
+var (
+	watchInterval      time.Duration
+	watchOnce          bool
+	watchEmitExisting  bool
+	watchStatePath     string
+	watchExec          string
+	watchWebhook       string
+	watchWebhookSecret string
+)
+
+var tasksWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch tasks and emit change events",
+	Long: "Polls /api/tasks and emits task.created and task.closed events as NDJSON on stdout. Each event can also be " +
+		"passed to a command (task JSON on stdin, event name in LABRADOC_EVENT) and POSTed to a webhook, optionally signed with HMAC-SHA256. " +
+		"Seen tasks are persisted after every delivered event so restarts do not repeat notifications.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if watchInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		path := watchStatePath
+		if path == "" {
+			p, err := cli.StatePath(tasksWatchStateFile)
+			if err != nil {
+				return err
+			}
+			path = p
+		}
+		state, err := loadWatchState(path)
+		if err != nil {
+			return err
+		}
+		secret := watchWebhookSecret
+		if secret == "" {
+			secret = viper.GetString("webhook_secret")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		cmd.SetContext(ctx)
+
+		for {
+			if err := pollTasks(cmd, path, state, secret); err != nil {
+				if watchOnce {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+			}
+			if watchOnce {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(watchInterval):
+			}
+		}
+	},
+}
+
+type watchedTask struct {
+	Title  string `json:"title,omitempty"`
+	Closed bool   `json:"closed"`
+}
+
+type watchState struct {
+	Initialized bool                   `json:"initialized"`
+	Tasks       map[string]watchedTask `json:"tasks"`
+	// Delivered lists, per event still being retried, the sinks that already
+	// have it, so a retry only goes to the ones that failed.
+	Delivered map[string][]string `json:"delivered,omitempty"`
+}
+
+type taskEvent struct {
+	Event string         `json:"event"`
+	Time  time.Time      `json:"time"`
+	Task  map[string]any `json:"task"`
+}
+
+// pollTasks diffs the current tasks against state and delivers one event per
+// change. State is saved after each delivered event; an event whose hooks
+// fail is left out of the state so the next poll retries it with the hooks
+// that have not run yet.
+func pollTasks(cmd *cobra.Command, path string, state *watchState, secret string) error {
+	tasks, err := fetchTasks(cmd)
+	if err != nil {
+		return err
+	}
+	if !state.Initialized && !watchEmitExisting {
+		for _, t := range tasks {
+			state.Tasks[t.ID] = watchedTask{Title: t.Title, Closed: t.Closed}
+		}
+		state.Initialized = true
+		return saveWatchState(path, state)
+	}
+	state.Initialized = true
+
+	current := make(map[string]apiTask, len(tasks))
+	var events []taskEvent
+	now := time.Now().UTC()
+	for _, t := range tasks {
+		current[t.ID] = t
+		prev, known := state.Tasks[t.ID]
+		switch {
+		case !known && !t.Closed:
+			events = append(events, taskEvent{Event: taskEventCreated, Time: now, Task: t.Raw})
+		case known && !prev.Closed && t.Closed:
+			events = append(events, taskEvent{Event: taskEventClosed, Time: now, Task: t.Raw})
+		case !known, prev.Closed && !t.Closed:
+			// Reopened tasks are tracked as open again so a later close is reported.
+			state.Tasks[t.ID] = watchedTask{Title: t.Title, Closed: t.Closed}
+		}
+	}
+	// Tasks that disappear from the list are treated as closed.
+	for id, prev := range state.Tasks {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if prev.Closed {
+			delete(state.Tasks, id)
+			continue
+		}
+		events = append(events, taskEvent{Event: taskEventClosed, Time: now, Task: map[string]any{"id": id, "title": prev.Title}})
+	}
+
+	pending := make(map[string]struct{}, len(events))
+	for _, ev := range events {
+		pending[ev.Event+" "+stringField(ev.Task, "id", "taskId", "uuid")] = struct{}{}
+	}
+	for key := range state.Delivered {
+		if _, ok := pending[key]; !ok {
+			delete(state.Delivered, key)
+		}
+	}
+
+	var failed int
+	for _, ev := range events {
+		id := stringField(ev.Task, "id", "taskId", "uuid")
+		key := ev.Event + " " + id
+		done := map[string]bool{}
+		for _, sink := range state.Delivered[key] {
+			done[sink] = true
+		}
+		if err := deliverTaskEvent(cmd.Context(), ev, secret, done); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "watch: deliver %s for %v: %v\n", ev.Event, ev.Task["id"], err)
+			var sinks []string
+			for sink := range done {
+				sinks = append(sinks, sink)
+			}
+			sort.Strings(sinks)
+			if len(sinks) > 0 {
+				if state.Delivered == nil {
+					state.Delivered = map[string][]string{}
+				}
+				state.Delivered[key] = sinks
+			}
+			continue
+		}
+		delete(state.Delivered, key)
+		if t, ok := current[id]; ok {
+			state.Tasks[id] = watchedTask{Title: t.Title, Closed: t.Closed}
+		} else {
+			delete(state.Tasks, id)
+		}
+		if err := saveWatchState(path, state); err != nil {
+			return err
+		}
+	}
+	if err := saveWatchState(path, state); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d events could not be delivered and will be retried", failed)
+	}
+	return nil
+}
+
+// deliverTaskEvent runs the exec hook and the webhook for ev, skipping the
+// sinks already in done and adding the ones that succeed. The event is
+// printed once all hooks have it.
+func deliverTaskEvent(ctx context.Context, ev taskEvent, secret string, done map[string]bool) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if watchExec != "" && !done[watchSinkExec] {
+		task, err := json.Marshal(ev.Task)
+		if err != nil {
+			return err
+		}
+		var c *exec.Cmd
+		if runtime.GOOS == "windows" {
+			c = exec.CommandContext(ctx, "cmd", "/C", watchExec)
+		} else {
+			c = exec.CommandContext(ctx, "sh", "-c", watchExec)
+		}
+		c.Stdin = bytes.NewReader(task)
+		c.Stdout = os.Stderr
+		c.Stderr = os.Stderr
+		c.Env = append(os.Environ(), "LABRADOC_EVENT="+ev.Event)
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("exec hook: %w", err)
+		}
+		done[watchSinkExec] = true
+	}
+	if watchWebhook != "" && !done[watchSinkWebhook] {
+		if err := postWebhook(ctx, watchWebhook, ev.Event, b, secret); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		done[watchSinkWebhook] = true
+	}
+	fmt.Fprintln(os.Stdout, string(b))
+	return nil
+}
+
+// postWebhook POSTs the event. With a secret, X-Labradoc-Signature carries
+// "sha256=" plus the hex HMAC-SHA256 of the body.
+func postWebhook(ctx context.Context, endpoint, event string, body []byte, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Labradoc-Event", event)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Labradoc-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func loadWatchState(path string) (*watchState, error) {
+	s := &watchState{Tasks: map[string]watchedTask{}}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if s.Tasks == nil {
+		s.Tasks = map[string]watchedTask{}
+	}
+	return s, nil
+}
+
+func saveWatchState(path string, s *watchState) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func init() {
+	tasksCmd.AddCommand(tasksWatchCmd)
+
+	tasksWatchCmd.Flags().DurationVar(&watchInterval, "interval", time.Minute, "Polling interval")
+	tasksWatchCmd.Flags().BoolVar(&watchOnce, "once", false, "Poll once and exit (for cron)")
+	tasksWatchCmd.Flags().BoolVar(&watchEmitExisting, "emit-existing", false, "On the first run, emit task.created for tasks that already exist")
+	tasksWatchCmd.Flags().StringVar(&watchStatePath, "state-file", "", "State file (default "+tasksWatchStateFile+" in the CLI config directory)")
+	tasksWatchCmd.Flags().StringVar(&watchExec, "exec", "", "Command to run per event, with the task JSON on stdin")
+	tasksWatchCmd.Flags().StringVar(&watchWebhook, "webhook", "", "URL to POST each event to")
+	tasksWatchCmd.Flags().StringVar(&watchWebhookSecret, "webhook-secret", "", "HMAC-SHA256 secret for X-Labradoc-Signature (default from webhook_secret)")
+}
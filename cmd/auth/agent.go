@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+const agentStartTimeout = 10 * time.Second
+
+var (
+	agentDetach        bool
+	agentRefreshBefore time.Duration
+	agentStatusJSON    bool
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run a background agent that keeps the access token fresh",
+	Long: "Runs a token agent for the active profile. The agent owns the stored token, renews it before it " +
+		"expires and hands out access tokens on a Unix socket in the profile directory that only the current " +
+		"user can open. API commands use the agent automatically when it is running and read the token file " +
+		"otherwise.\n\nThe agent runs in the foreground until interrupted; --detach starts it in the background.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if _, err := cli.LoadToken(); err != nil {
+			return err
+		}
+		if agentDetach {
+			return startDetachedAgent(cmd)
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		socket, err := cli.AgentSocketPath()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Token agent listening on %s\n", socket)
+		return cli.RunAgent(ctx, agentRefreshBefore)
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the token agent is running",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		st, err := cli.AgentStatusInfo(cmd.Context())
+		running := err == nil
+		if err != nil && !errors.Is(err, cli.ErrAgentNotRunning) {
+			return err
+		}
+		socket, _ := cli.AgentSocketPath()
+		if agentStatusJSON {
+			out := map[string]any{"running": running, "socket": socket}
+			if running {
+				out["agent"] = st
+			}
+			b, _ := json.MarshalIndent(out, "", "  ")
+			fmt.Fprintln(os.Stdout, string(b))
+			return nil
+		}
+		if !running {
+			fmt.Fprintln(os.Stdout, "Token agent not running.")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "PID:\t%d\n", st.PID)
+		fmt.Fprintf(tw, "Profile:\t%s\n", st.Profile)
+		fmt.Fprintf(tw, "Socket:\t%s\n", socket)
+		fmt.Fprintf(tw, "Started:\t%s\n", st.Started.Local().Format("2006-01-02 15:04:05"))
+		if !st.Expiry.IsZero() {
+			fmt.Fprintf(tw, "Token expires:\t%s\n", st.Expiry.Local().Format("2006-01-02 15:04:05"))
+		}
+		fmt.Fprintf(tw, "Refreshes:\t%d\n", st.Refreshes)
+		if !st.LastRefresh.IsZero() {
+			fmt.Fprintf(tw, "Last refresh:\t%s\n", st.LastRefresh.Local().Format("2006-01-02 15:04:05"))
+		}
+		if st.LastError != "" {
+			fmt.Fprintf(tw, "Last error:\t%s\n", st.LastError)
+		}
+		return tw.Flush()
+	},
+}
+
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the token agent",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if err := cli.StopAgent(cmd.Context()); err != nil {
+			if errors.Is(err, cli.ErrAgentNotRunning) {
+				fmt.Fprintln(os.Stdout, "Token agent not running.")
+				return nil
+			}
+			return err
+		}
+		fmt.Fprintln(os.Stdout, "Token agent stopped.")
+		return nil
+	},
+}
+
+// startDetachedAgent runs this command again without --detach in a new
+// session, with its output going to agent.log next to the socket, and waits
+// until the agent answers.
+func startDetachedAgent(cmd *cobra.Command) error {
+	if cli.ActiveTokenStore().Kind() == cli.StoreEncrypted &&
+		os.Getenv(cli.EnvTokenKey) == "" && os.Getenv(cli.EnvTokenPassphrase) == "" {
+		return fmt.Errorf("a detached agent cannot prompt for the token passphrase; set %s or %s, or run it in the foreground",
+			cli.EnvTokenKey, cli.EnvTokenPassphrase)
+	}
+	if _, err := cli.AgentStatusInfo(cmd.Context()); err == nil {
+		return errors.New("a token agent is already running for this profile")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	socket, err := cli.AgentSocketPath()
+	if err != nil {
+		return err
+	}
+	logPath := filepath.Join(filepath.Dir(socket), "agent.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg != "--detach" && arg != "--detach=true" {
+			args = append(args, arg)
+		}
+	}
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = detachedProcAttr()
+	if err := child.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), agentStartTimeout)
+	defer cancel()
+	for {
+		if st, err := cli.AgentStatusInfo(ctx); err == nil {
+			fmt.Fprintf(os.Stdout, "Token agent started (pid %d).\n", st.PID)
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("token agent exited: %v (see %s)", err, logPath)
+		case <-ctx.Done():
+			return fmt.Errorf("token agent did not start within %s (see %s)", agentStartTimeout, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentStopCmd)
+
+	agentCmd.Flags().BoolVar(&agentDetach, "detach", false, "Run the agent in the background")
+	agentCmd.Flags().DurationVar(&agentRefreshBefore, "refresh-before", cli.DefaultAgentRefreshBefore, "Renew the access token this long before it expires")
+	agentStatusCmd.Flags().BoolVar(&agentStatusJSON, "json", false, "Output machine-readable JSON")
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package auth
+
+import "syscall"
+
+// detachedProcAttr starts the agent in its own session so it outlives the
+// terminal that launched it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
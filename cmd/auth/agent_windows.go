@@ -0,0 +1,16 @@
+//go:build windows
+
+package auth
+
+import "syscall"
+
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the agent without a console so it outlives the
+// terminal that launched it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
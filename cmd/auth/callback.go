@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// callbackPage is what the browser shows after the IdP redirects back to
+// the login listener.
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Labradoc CLI – {{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f5f6f8; color: #1f2328; display: flex; justify-content: center; padding-top: 15vh; margin: 0; }
+main { background: #fff; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.12); padding: 2rem 2.5rem; max-width: 32rem; }
+h1 { font-size: 1.4rem; margin: 0 0 .75rem; color: {{if .Failed}}#c62828{{else}}#2e7d32{{end}}; }
+p { line-height: 1.5; margin: .5rem 0; }
+code { background: #f0f1f3; border-radius: 4px; padding: .1rem .3rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Detail}}<p><code>{{.Detail}}</code></p>{{end}}
+<p>You can close this window and return to the terminal.</p>
+</main>
+</body>
+</html>
+`))
+
+type callbackResult struct {
+	Title   string
+	Message string
+	Detail  string
+	Failed  bool
+}
+
+func writeCallbackPage(w http.ResponseWriter, status int, page callbackResult) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = callbackPage.Execute(w, page)
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	loginDevice bool
+	loginQR     bool
+)
+
+// loginWithDevice runs the device authorization grant (RFC 8628): the user
+// opens the verification URI on any device and enters the user code while
+// the CLI polls the token endpoint.
+func loginWithDevice(cmd *cobra.Command) (*cli.Token, error) {
+	authURL, realm, clientID, scope, err := resolveAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	ctx := cmd.Context()
+	if cmd.Flags().Changed("timeout") {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, loginTimeout)
+		defer cancel()
+	}
+	da, err := cli.StartDeviceAuthorization(ctx, authURL, realm, clientID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stdout
+	if loginJSON {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "On any device, open:\n%s\nand enter the code: %s\n", da.VerificationURI, da.UserCode)
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "Or open this URL, which includes the code:\n%s\n", da.VerificationURIComplete)
+	}
+	if loginQR {
+		target := da.VerificationURIComplete
+		if target == "" {
+			target = da.VerificationURI
+		}
+		fmt.Fprintln(out)
+		if err := cli.WriteQR(out, target); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintln(out, "Waiting for approval...")
+	return cli.PollDeviceToken(ctx, authURL, realm, clientID, da)
+}
+
+func init() {
+	loginCmd.Flags().BoolVar(&loginDevice, "device", false, "Log in with the device authorization grant (no local browser or callback needed)")
+	loginCmd.Flags().BoolVar(&loginQR, "qr", false, "With --device, also print the verification URL as a terminal QR code")
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	endpointsRefresh bool
+	endpointsJSON    bool
+)
+
+var endpointsCmd = &cobra.Command{
+	Use:   "endpoints",
+	Short: "Show the OIDC endpoints in use",
+	Long: "Shows the endpoints from the issuer's .well-known/openid-configuration (cached for keycloak.discovery_ttl, " +
+		"default 24h) or, when discovery is unavailable, the ones built from the Keycloak realm URL.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		authURL, realm, _, _, err := resolveAuthConfig()
+		if err != nil {
+			return err
+		}
+		var p *cli.ProviderMetadata
+		if endpointsRefresh {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			defer cancel()
+			if p, err = cli.RefreshProvider(ctx, authURL, realm); err != nil {
+				return err
+			}
+		} else {
+			p = cli.Provider(authURL, realm)
+		}
+		if endpointsJSON {
+			out := map[string]any{"source": p.Source, "discovery_url": cli.IssuerURL(authURL, realm) + "/.well-known/openid-configuration"}
+			b, _ := json.Marshal(p)
+			_ = json.Unmarshal(b, &out)
+			b, _ = json.MarshalIndent(out, "", "  ")
+			fmt.Fprintln(os.Stdout, string(b))
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Source:\t%s\n", p.Source)
+		fmt.Fprintf(tw, "Issuer:\t%s\n", p.Issuer)
+		fmt.Fprintf(tw, "Authorization:\t%s\n", orDash(p.AuthorizationEndpoint))
+		fmt.Fprintf(tw, "Token:\t%s\n", orDash(p.TokenEndpoint))
+		fmt.Fprintf(tw, "Device:\t%s\n", orDash(p.DeviceAuthorizationEndpoint))
+		fmt.Fprintf(tw, "Revocation:\t%s\n", orDash(p.RevocationEndpoint))
+		fmt.Fprintf(tw, "End session:\t%s\n", orDash(p.EndSessionEndpoint))
+		fmt.Fprintf(tw, "JWKS:\t%s\n", orDash(p.JWKSURI))
+		if !p.FetchedAt.IsZero() {
+			fmt.Fprintf(tw, "Fetched:\t%s\n", p.FetchedAt.Local().Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	},
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	RootCmd.AddCommand(endpointsCmd)
+
+	endpointsCmd.Flags().BoolVar(&endpointsRefresh, "refresh", false, "Fetch the discovery document again instead of using the cache")
+	endpointsCmd.Flags().BoolVar(&endpointsJSON, "json", false, "Output machine-readable JSON")
+}
@@ -17,15 +17,41 @@ var (
 	exchangeVerifier    string
 	exchangeRedirectURI string
 	exchangeState       string
+	exchangeRedirectURL string
 	exchangeJSON        bool
 )
 
 var exchangeCmd = &cobra.Command{
 	Use:   "exchange",
 	Short: "Exchange an OAuth code for a token",
+	Long: "Exchanges an authorization code using the pending PKCE session from auth url. The session is picked by " +
+		"--state, by the state in --redirect-url, or is the only pending one. --redirect-url takes the full URL the " +
+		"browser was redirected to and reads the code and state from it.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		if exchangeCode == "" {
-			return fmt.Errorf("missing --code")
+		code := exchangeCode
+		state := exchangeState
+		codeVerifier := exchangeVerifier
+		redirectURI := exchangeRedirectURI
+
+		var fallbackRedirectURI string
+		if exchangeRedirectURL != "" {
+			urlCode, urlState, urlRedirectURI, err := cli.ParseRedirectURL(exchangeRedirectURL)
+			if err != nil {
+				return err
+			}
+			if state != "" && urlState != "" && state != urlState {
+				return fmt.Errorf("--state does not match the state in --redirect-url")
+			}
+			if code == "" {
+				code = urlCode
+			}
+			if state == "" {
+				state = urlState
+			}
+			fallbackRedirectURI = urlRedirectURI
+		}
+		if code == "" {
+			return fmt.Errorf("missing --code or --redirect-url")
 		}
 
 		authURL, realm, clientID, _, err := resolveAuthConfig()
@@ -33,35 +59,29 @@ var exchangeCmd = &cobra.Command{
 			return err
 		}
 
-		state := exchangeState
-		codeVerifier := exchangeVerifier
-		redirectURI := exchangeRedirectURI
-
-		if codeVerifier == "" || redirectURI == "" || state == "" {
-			if pkce, err := cli.LoadPKCEState(); err == nil {
-				if state != "" && pkce.State != "" && pkce.State != state {
-					return fmt.Errorf("state mismatch between stored PKCE and provided state")
-				}
-				if codeVerifier == "" {
-					codeVerifier = pkce.CodeVerifier
-				}
-				if redirectURI == "" {
-					redirectURI = pkce.RedirectURI
-				}
-				if state == "" {
-					state = pkce.State
-				}
-			}
+		// With an explicit verifier no stored session is needed; the redirect
+		// URI then comes from --redirect-url.
+		if codeVerifier != "" && redirectURI == "" {
+			redirectURI = fallbackRedirectURI
 		}
-
 		if codeVerifier == "" || redirectURI == "" {
-			return fmt.Errorf("missing code verifier or redirect uri")
+			pkce, err := cli.LoadPKCESession(state)
+			if err != nil {
+				return err
+			}
+			if codeVerifier == "" {
+				codeVerifier = pkce.CodeVerifier
+			}
+			if redirectURI == "" {
+				redirectURI = pkce.RedirectURI
+			}
+			state = pkce.State
 		}
 
 		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
 		defer cancel()
 
-		token, err := cli.ExchangeCode(ctx, authURL, realm, clientID, exchangeCode, redirectURI, codeVerifier)
+		token, err := cli.ExchangeCode(ctx, authURL, realm, clientID, code, redirectURI, codeVerifier)
 		if err != nil {
 			return err
 		}
@@ -71,7 +91,9 @@ var exchangeCmd = &cobra.Command{
 		if err := cli.SaveToken(*token); err != nil {
 			return err
 		}
-		_ = cli.ClearPKCEState()
+		if state != "" {
+			_ = cli.RemovePKCESession(state)
+		}
 
 		if exchangeJSON {
 			out := map[string]any{
@@ -93,6 +115,7 @@ func init() {
 	exchangeCmd.Flags().StringVar(&exchangeCode, "code", "", "Authorization code from the redirect")
 	exchangeCmd.Flags().StringVar(&exchangeVerifier, "code-verifier", "", "PKCE code verifier")
 	exchangeCmd.Flags().StringVar(&exchangeRedirectURI, "redirect-uri", "", "Redirect URI used in authorization")
-	exchangeCmd.Flags().StringVar(&exchangeState, "state", "", "State returned from authorization")
+	exchangeCmd.Flags().StringVar(&exchangeState, "state", "", "State returned from authorization; selects the PKCE session")
+	exchangeCmd.Flags().StringVar(&exchangeRedirectURL, "redirect-url", "", "Full redirect URL after login (code and state are read from it)")
 	exchangeCmd.Flags().BoolVar(&exchangeJSON, "json", false, "Output machine-readable JSON")
 }
@@ -13,97 +13,36 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	loginTimeout time.Duration
-	loginJSON    bool
+	loginTimeout      time.Duration
+	loginJSON         bool
+	loginNoBrowser    bool
+	loginCallbackPort int
 )
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login via OAuth PKCE using a local callback",
+	Long: "Logs in with OAuth PKCE through a local callback listener on 127.0.0.1. The login page opens in the " +
+		"system browser unless --no-browser is set. --callback-port fixes the listener port for clients registered " +
+		"with an exact redirect URI. " +
+		"--device uses the device authorization grant instead, for machines without a local browser. " +
+		"--client-credentials logs in as a service account; api commands then use its token without --use-auth-token " +
+		"and request a new one when it expires.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		authURL, realm, clientID, scope, err := resolveAuthConfig()
-		if err != nil {
-			return err
+		var token *cli.Token
+		var err error
+		switch {
+		case loginDevice:
+			token, err = loginWithDevice(cmd)
+		case loginClientCredentials:
+			token, err = loginWithClientCredentials(cmd)
+		default:
+			token, err = loginWithCallback(cmd)
 		}
-
-		codeVerifier, codeChallenge, err := cli.GeneratePKCE()
-		if err != nil {
-			return err
-		}
-
-		state := uuid.NewString()
-		listener, err := net.Listen("tcp", "127.0.0.1:0")
-		if err != nil {
-			return err
-		}
-		defer listener.Close()
-
-		port := listener.Addr().(*net.TCPAddr).Port
-		redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", port)
-
-		authURLString, err := cli.AuthURL(authURL, realm, clientID, redirectURI, scope, state, codeChallenge)
-		if err != nil {
-			return err
-		}
-
-		if loginJSON {
-			fmt.Fprintf(os.Stderr, "Open this URL to authenticate:\n%s\n", authURLString)
-		} else {
-			fmt.Fprintf(os.Stdout, "Open this URL to authenticate:\n%s\n", authURLString)
-		}
-
-		codeCh := make(chan string, 1)
-		errCh := make(chan error, 1)
-
-		server := &http.Server{
-			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if r.URL.Path != "/callback" {
-					w.WriteHeader(http.StatusNotFound)
-					return
-				}
-				q := r.URL.Query()
-				code := q.Get("code")
-				if code == "" {
-					w.WriteHeader(http.StatusBadRequest)
-					_, _ = w.Write([]byte("Missing code"))
-					return
-				}
-				if s := q.Get("state"); s != "" && s != state {
-					w.WriteHeader(http.StatusBadRequest)
-					_, _ = w.Write([]byte("State mismatch"))
-					return
-				}
-				_, _ = w.Write([]byte("Authentication complete. You can close this window."))
-				codeCh <- code
-			}),
-		}
-
-		go func() {
-			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
-				errCh <- err
-			}
-		}()
-
-		ctx, cancel := context.WithTimeout(cmd.Context(), loginTimeout)
-		defer cancel()
-
-		var code string
-		select {
-		case <-ctx.Done():
-			_ = server.Shutdown(context.Background())
-			return fmt.Errorf("timed out waiting for authentication")
-		case err := <-errCh:
-			_ = server.Shutdown(context.Background())
-			return err
-		case code = <-codeCh:
-		}
-
-		_ = server.Shutdown(context.Background())
-
-		token, err := cli.ExchangeCode(ctx, authURL, realm, clientID, code, redirectURI, codeVerifier)
 		if err != nil {
 			return err
 		}
@@ -130,7 +69,146 @@ var loginCmd = &cobra.Command{
 	},
 }
 
+func loginWithCallback(cmd *cobra.Command) (*cli.Token, error) {
+	authURL, realm, clientID, scope, err := resolveAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	codeVerifier, codeChallenge, err := cli.GeneratePKCE()
+	if err != nil {
+		return nil, err
+	}
+
+	port := loginCallbackPort
+	if !cmd.Flags().Changed("callback-port") {
+		if p := viper.GetInt("keycloak.callback_port"); p > 0 {
+			port = p
+		}
+	}
+	state := uuid.NewString()
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		if port != 0 {
+			return nil, fmt.Errorf("callback port %d: %w", port, err)
+		}
+		return nil, err
+	}
+	defer listener.Close()
+
+	port = listener.Addr().(*net.TCPAddr).Port
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+
+	authURLString, err := cli.AuthURL(authURL, realm, clientID, redirectURI, scope, state, codeChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	out := os.Stdout
+	if loginJSON {
+		out = os.Stderr
+	}
+	if !loginNoBrowser && cli.OpenBrowser(authURLString) == nil {
+		fmt.Fprintf(out, "Opened the browser to authenticate. If it did not open, visit:\n%s\n", authURLString)
+	} else {
+		fmt.Fprintf(out, "Open this URL to authenticate:\n%s\n", authURLString)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), loginTimeout)
+	defer cancel()
+
+	type result struct {
+		token *cli.Token
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	errCh := make(chan error, 1)
+	// Only the first outcome counts; a repeated callback must not block.
+	finish := func(res result) {
+		select {
+		case resultCh <- res:
+		default:
+		}
+	}
+
+	// The code is exchanged inside the handler so the page shows the real
+	// outcome. Requests without this login's state are rejected without
+	// ending the login; an error from the IdP with the right state ends it
+	// at once.
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/callback" {
+				http.NotFound(w, r)
+				return
+			}
+			q := r.URL.Query()
+			if q.Get("state") != state {
+				writeCallbackPage(w, http.StatusBadRequest, callbackResult{
+					Title:   "Login failed",
+					Message: "The login response does not belong to this login attempt (state mismatch).",
+					Failed:  true,
+				})
+				return
+			}
+			if err := cli.AuthorizationError(q); err != nil {
+				writeCallbackPage(w, http.StatusBadRequest, callbackResult{
+					Title:   "Login failed",
+					Message: "The identity provider returned an error.",
+					Detail:  err.Error(),
+					Failed:  true,
+				})
+				finish(result{err: fmt.Errorf("login failed: %w", err)})
+				return
+			}
+			code := q.Get("code")
+			if code == "" {
+				writeCallbackPage(w, http.StatusBadRequest, callbackResult{
+					Title:   "Login failed",
+					Message: "The login response contains no authorization code.",
+					Failed:  true,
+				})
+				return
+			}
+			token, err := cli.ExchangeCode(ctx, authURL, realm, clientID, code, redirectURI, codeVerifier)
+			if err != nil {
+				writeCallbackPage(w, http.StatusBadGateway, callbackResult{
+					Title:   "Login failed",
+					Message: "The authorization code could not be exchanged for a token.",
+					Detail:  err.Error(),
+					Failed:  true,
+				})
+				finish(result{err: err})
+				return
+			}
+			writeCallbackPage(w, http.StatusOK, callbackResult{
+				Title:   "Login successful",
+				Message: "The Labradoc CLI is now signed in.",
+			})
+			finish(result{token: token})
+		}),
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for authentication")
+	case err := <-errCh:
+		return nil, err
+	case res := <-resultCh:
+		return res.token, res.err
+	}
+}
+
 func init() {
 	loginCmd.Flags().DurationVar(&loginTimeout, "timeout", 2*time.Minute, "Wait timeout for callback")
 	loginCmd.Flags().BoolVar(&loginJSON, "json", false, "Output machine-readable JSON")
+	loginCmd.Flags().BoolVar(&loginNoBrowser, "no-browser", false, "Print the login URL instead of opening the browser")
+	loginCmd.Flags().IntVar(&loginCallbackPort, "callback-port", 0, "Fixed port for the callback listener (default from keycloak.callback_port; 0 picks a free port)")
+	loginCmd.MarkFlagsMutuallyExclusive("device", "client-credentials")
 }
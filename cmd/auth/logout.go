@@ -1,22 +1,91 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/zamedic/labradoc-cli/internal/cli"
 
 	"github.com/spf13/cobra"
 )
 
+var logoutLocalOnly bool
+
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
-	Short: "Delete the stored token",
+	Short: "Revoke the session and delete the stored token",
+	Long: "Revokes the refresh token at the provider's revocation endpoint, ends the SSO session at the end-session " +
+		"endpoint with the ID token as hint, then deletes the stored token and any pending PKCE state. " +
+		"A running token agent is stopped first. Local state is deleted even when the server calls fail; the command then exits with an error. " +
+		"A stored token that cannot be read (for example without the passphrase) is left in place and the command fails. " +
+		"--local-only skips the server calls.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		// A running agent would keep handing out the revoked session.
+		if err := cli.StopAgent(cmd.Context()); err != nil && !errors.Is(err, cli.ErrAgentNotRunning) {
+			return err
+		}
+		var serverErrs []error
+		if !logoutLocalOnly {
+			// Only a missing token means there is nothing to revoke. A token
+			// that cannot be read is kept so the logout can be retried.
+			tok, err := cli.LoadToken()
+			switch {
+			case err == nil:
+				serverErrs = serverLogout(cmd.Context(), tok)
+			case !errors.Is(err, os.ErrNotExist):
+				return fmt.Errorf("read stored token: %w; the session was not revoked and nothing was deleted (use --local-only to only delete local state)", err)
+			}
+		}
+
 		if err := cli.ClearToken(); err != nil {
 			return err
 		}
+		if err := cli.ClearPKCEState(); err != nil {
+			return err
+		}
 		fmt.Fprintln(os.Stdout, "Token removed.")
+
+		if len(serverErrs) > 0 {
+			return fmt.Errorf("local token removed, but the server logout failed: %w", errors.Join(serverErrs...))
+		}
 		return nil
 	},
 }
+
+func serverLogout(ctx context.Context, tok *cli.Token) []error {
+	authURL, realm, clientID := tok.AuthURL, tok.Realm, tok.ClientID
+	if authURL == "" || realm == "" || clientID == "" {
+		var err error
+		if authURL, realm, clientID, _, err = resolveAuthConfig(); err != nil {
+			return []error{err}
+		}
+	} else {
+		cli.UseIssuer(flagOrConfig("issuer", issuerFlag, "keycloak.issuer"), 0)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var errs []error
+	if tok.RefreshToken != "" {
+		if err := cli.RevokeToken(ctx, authURL, realm, clientID, tok.RefreshToken, "refresh_token"); err != nil {
+			errs = append(errs, err)
+		} else {
+			fmt.Fprintln(os.Stdout, "Refresh token revoked.")
+		}
+	}
+	if tok.IDToken != "" {
+		if err := cli.EndSession(ctx, authURL, realm, clientID, tok.IDToken); err != nil {
+			errs = append(errs, err)
+		} else {
+			fmt.Fprintln(os.Stdout, "SSO session ended.")
+		}
+	}
+	return errs
+}
+
+func init() {
+	logoutCmd.Flags().BoolVar(&logoutLocalOnly, "local-only", false, "Only delete local state; do not revoke the session on the server")
+}
@@ -26,28 +26,38 @@ var refreshCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		if tok.RefreshToken == "" {
-			return fmt.Errorf("no refresh_token available")
-		}
 
 		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
 		defer cancel()
 
-		newTok, err := cli.RefreshToken(ctx, authURL, realm, clientID, tok.RefreshToken)
+		// The stored token is renewed under a lock; if another process
+		// refreshed it since it was loaded, that token is kept.
+		newTok, renewed, err := cli.UpdateToken(tok, func(cur *cli.Token) (*cli.Token, error) {
+			if cur.Grant == cli.GrantClientCredentials {
+				// Service-account tokens have no refresh token.
+				return cli.RenewToken(ctx, cur)
+			}
+			if cur.RefreshToken == "" {
+				return nil, fmt.Errorf("no refresh_token available")
+			}
+			fresh, err := cli.RefreshToken(ctx, authURL, realm, clientID, cur.RefreshToken)
+			if err != nil {
+				return nil, err
+			}
+			if fresh.RefreshToken == "" {
+				fresh.RefreshToken = cur.RefreshToken
+			}
+			fresh.APIURL = cur.APIURL
+			return fresh, nil
+		})
 		if err != nil {
 			return err
 		}
-		if newTok.RefreshToken == "" {
-			newTok.RefreshToken = tok.RefreshToken
-		}
-		newTok.APIURL = tok.APIURL
-		if err := cli.SaveToken(*newTok); err != nil {
-			return err
-		}
 
 		if refreshJSON {
 			out := map[string]any{
 				"status":     "ok",
+				"renewed":    renewed,
 				"expires_at": newTok.Expiry,
 				"scope":      newTok.Scope,
 			}
@@ -56,6 +66,10 @@ var refreshCmd = &cobra.Command{
 			return nil
 		}
 
+		if !renewed {
+			fmt.Fprintln(os.Stdout, "Token already refreshed by another process.")
+			return nil
+		}
 		fmt.Fprintln(os.Stdout, "Token refreshed.")
 		return nil
 	},
@@ -3,6 +3,8 @@ package auth
 import (
 	"fmt"
 
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,6 +15,7 @@ var (
 	clientIDFlag string
 	apiURLFlag   string
 	scopeFlag    string
+	issuerFlag   string
 )
 
 var RootCmd = &cobra.Command{
@@ -23,9 +26,10 @@ var RootCmd = &cobra.Command{
 func init() {
 	RootCmd.PersistentFlags().StringVar(&authURLFlag, "auth-url", "https://auth.labradoc.eu", "Keycloak base URL (default from keycloak.url)")
 	RootCmd.PersistentFlags().StringVar(&realmFlag, "realm", "labradoc", "Keycloak realm (default from keycloak.realm)")
-	RootCmd.PersistentFlags().StringVar(&clientIDFlag, "client-id", "labradoc-openclaw", "OAuth client ID")
+	RootCmd.PersistentFlags().StringVar(&clientIDFlag, "client-id", "labradoc-openclaw", "OAuth client ID (default from keycloak.client_id)")
 	RootCmd.PersistentFlags().StringVar(&apiURLFlag, "api-url", "https://labradoc.eu", "API base URL (default from api_url)")
 	RootCmd.PersistentFlags().StringVar(&scopeFlag, "scope", "openid profile email offline_access", "OAuth scopes")
+	RootCmd.PersistentFlags().StringVar(&issuerFlag, "issuer", "", "OIDC issuer URL for endpoint discovery (default from keycloak.issuer, else <auth-url>/realms/<realm>)")
 
 	RootCmd.AddCommand(loginCmd)
 	RootCmd.AddCommand(urlCmd)
@@ -36,36 +40,31 @@ func init() {
 	RootCmd.AddCommand(logoutCmd)
 }
 
-func resolveAuthConfig() (string, string, string, string, error) {
-	authURL := authURLFlag
-	if authURL == "" {
-		authURL = viper.GetString("keycloak.url")
-	}
-	if authURL == "" {
-		authURL = "https://auth.labradoc.eu"
-	}
-	realm := realmFlag
-	if realm == "" {
-		realm = viper.GetString("keycloak.realm")
-	}
-	if realm == "" {
-		realm = "labradoc"
+// flagOrConfig prefers an explicitly set flag, then the config key (from the
+// environment, the active profile or labrador.yaml), then the flag default.
+func flagOrConfig(flag, value, key string) string {
+	if RootCmd.PersistentFlags().Changed(flag) {
+		return value
 	}
-	clientID := clientIDFlag
-	if clientID == "" {
-		clientID = "labradoc-openclaw"
+	if v := viper.GetString(key); v != "" {
+		return v
 	}
+	return value
+}
+
+func resolveAuthConfig() (string, string, string, string, error) {
+	authURL := flagOrConfig("auth-url", authURLFlag, "keycloak.url")
+	realm := flagOrConfig("realm", realmFlag, "keycloak.realm")
+	clientID := flagOrConfig("client-id", clientIDFlag, "keycloak.client_id")
 	if authURL == "" || realm == "" || clientID == "" {
 		return "", "", "", "", fmt.Errorf("missing auth configuration (auth-url, realm, client-id)")
 	}
+	cli.UseIssuer(flagOrConfig("issuer", issuerFlag, "keycloak.issuer"), 0)
 	return authURL, realm, clientID, scopeFlag, nil
 }
 
 func resolveAPIURL() (string, error) {
-	apiURL := apiURLFlag
-	if apiURL == "" {
-		apiURL = viper.GetString("api_url")
-	}
+	apiURL := flagOrConfig("api-url", apiURLFlag, "api_url")
 	if apiURL == "" {
 		return "", fmt.Errorf("missing api url (api_url)")
 	}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Show how tokens and API keys are stored",
+	Long: "Shows the token store backend and how each profile's token is kept on disk. Select the backend with " +
+		"token_store (plaintext or encrypted) in labrador.yaml or TOKEN_STORE. The encrypted backend uses AES-256-GCM " +
+		"with the key from LABRADOC_TOKEN_KEY (32 bytes, hex or base64) or derived with scrypt from " +
+		"LABRADOC_TOKEN_PASSPHRASE or a passphrase prompt.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		store := cli.ActiveTokenStore()
+		fmt.Fprintf(os.Stdout, "Backend: %s\n", store.Kind())
+		if store.Kind() == cli.StoreEncrypted {
+			fmt.Fprintf(os.Stdout, "Key: %s\n", cli.KeySource())
+		}
+		names, err := storeProfiles()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			form := cli.ProfileTokenForm(name)
+			if form == "" {
+				form = "no token"
+			}
+			fmt.Fprintf(os.Stdout, "Profile %s: %s\n", name, form)
+		}
+		return nil
+	},
+}
+
+var (
+	migrateTo     string
+	migrateDryRun bool
+)
+
+var storeMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Convert stored tokens and API keys to another backend",
+	Long: "Rewrites the token and PKCE files of every profile with the --to backend, and encrypts or decrypts the " +
+		"api_token values in profiles.yaml and in labrador*.yaml in the current directory. " +
+		"Set token_store to the same backend afterwards so new tokens are stored the same way.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		to, err := cli.NewTokenStore(migrateTo)
+		if err != nil {
+			return err
+		}
+		if to.Kind() == cli.StoreEncrypted && !migrateDryRun {
+			if err := cli.PromptNewPassphrase(); err != nil {
+				return err
+			}
+		}
+		names, err := storeProfiles()
+		if err != nil {
+			return err
+		}
+
+		converted := 0
+		for _, name := range names {
+			if migrateDryRun {
+				if form := cli.ProfileTokenForm(name); form != "" && form != to.Kind() {
+					fmt.Fprintf(os.Stdout, "would convert token of profile %s (%s)\n", name, form)
+					converted++
+				}
+				continue
+			}
+			paths, err := cli.MigrateProfileFiles(name, to)
+			for _, p := range paths {
+				fmt.Fprintf(os.Stdout, "converted %s\n", p)
+			}
+			converted += len(paths)
+			if err != nil {
+				return fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, name := range profiles.Names() {
+			p := profiles.Profiles[name]
+			value, ok, err := convertSecret(p.APIToken, to.Kind())
+			if err != nil {
+				return fmt.Errorf("profile %s api_token: %w", name, err)
+			}
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(os.Stdout, "%s api_token of profile %s\n", migrateVerb(), name)
+			p.APIToken = value
+			changed = true
+			converted++
+		}
+		if changed && !migrateDryRun {
+			if err := cli.SaveProfiles(profiles); err != nil {
+				return err
+			}
+		}
+
+		files, err := filepath.Glob("labrador*.yaml")
+		if err != nil {
+			return err
+		}
+		for _, path := range files {
+			ok, err := migrateConfigFile(path, to.Kind())
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if ok {
+				fmt.Fprintf(os.Stdout, "%s api_token in %s\n", migrateVerb(), path)
+				converted++
+			}
+		}
+
+		if converted == 0 {
+			fmt.Fprintf(os.Stdout, "Nothing to convert; everything is already %s.\n", to.Kind())
+		}
+		if current := cli.ActiveTokenStore().Kind(); current != to.Kind() {
+			fmt.Fprintf(os.Stderr, "Note: token_store is %s; set token_store: %s so new tokens are stored the same way.\n", current, to.Kind())
+		}
+		return nil
+	},
+}
+
+func migrateVerb() string {
+	if migrateDryRun {
+		return "would convert"
+	}
+	return "converted"
+}
+
+// storeProfiles returns the default profile followed by the named ones.
+func storeProfiles() ([]string, error) {
+	profiles, err := cli.LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{cli.DefaultProfile}, profiles.Names()...), nil
+}
+
+// convertSecret seals or opens a config value for the target backend and
+// reports whether it changed.
+func convertSecret(value, to string) (string, bool, error) {
+	if value == "" || cli.IsSealedSecret(value) == (to == cli.StoreEncrypted) {
+		return value, false, nil
+	}
+	if migrateDryRun {
+		return value, true, nil
+	}
+	var out string
+	var err error
+	if to == cli.StoreEncrypted {
+		out, err = cli.SealSecret(value)
+	} else {
+		out, err = cli.OpenSecret(value)
+	}
+	return out, err == nil, err
+}
+
+// migrateConfigFile converts the top-level api_token of a labrador*.yaml file
+// in place, keeping its comments and the order of its keys.
+func migrateConfigFile(path, to string) (bool, error) {
+	current, found, err := cli.ConfigFileValue(path, "api_token")
+	if err != nil || !found {
+		return false, err
+	}
+	value, ok, err := convertSecret(current, to)
+	if err != nil || !ok || migrateDryRun {
+		return ok, err
+	}
+	return true, cli.SetConfigFileValue(path, "api_token", value)
+}
+
+func init() {
+	RootCmd.AddCommand(storeCmd)
+	storeCmd.AddCommand(storeMigrateCmd)
+
+	storeMigrateCmd.Flags().StringVar(&migrateTo, "to", cli.StoreEncrypted, "Target backend: encrypted, plaintext")
+	storeMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show what would be converted without changing anything")
+}
@@ -10,17 +10,30 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
 	urlJSON        bool
 	urlRedirectURI string
+	urlTTL         time.Duration
 )
 
 var urlCmd = &cobra.Command{
 	Use:   "url",
 	Short: "Generate an OAuth PKCE authorization URL",
+	Long: "Generates an authorization URL and stores its PKCE session in pkce.json, keyed by state. Several " +
+		"sessions can be pending at once; each can be exchanged with auth exchange until it expires after --ttl.",
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		ttl := urlTTL
+		if !cmd.Flags().Changed("ttl") && viper.IsSet("keycloak.pkce_ttl") {
+			if ttl = viper.GetDuration("keycloak.pkce_ttl"); ttl <= 0 {
+				return fmt.Errorf("keycloak.pkce_ttl must be a positive duration, got %q", viper.GetString("keycloak.pkce_ttl"))
+			}
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("--ttl must be positive")
+		}
 		authURL, realm, clientID, scope, err := resolveAuthConfig()
 		if err != nil {
 			return err
@@ -41,13 +54,15 @@ var urlCmd = &cobra.Command{
 			return err
 		}
 
-		if err := cli.SavePKCEState(cli.PKCEState{
+		now := time.Now().UTC()
+		if err := cli.SavePKCESession(cli.PKCEState{
 			CodeVerifier:  codeVerifier,
 			CodeChallenge: codeChallenge,
 			State:         state,
 			RedirectURI:   redirectURI,
 			Scope:         scope,
-			CreatedAt:     time.Now().UTC(),
+			CreatedAt:     now,
+			ExpiresAt:     now.Add(ttl),
 		}); err != nil {
 			return err
 		}
@@ -63,6 +78,7 @@ var urlCmd = &cobra.Command{
 				"scope":             scope,
 				"code_verifier":     codeVerifier,
 				"code_challenge":    codeChallenge,
+				"expires_at":        now.Add(ttl).Format(time.RFC3339),
 			}
 			b, _ := json.Marshal(payload)
 			fmt.Fprintln(os.Stdout, string(b))
@@ -71,6 +87,7 @@ var urlCmd = &cobra.Command{
 
 		fmt.Fprintf(os.Stdout, "Authorization URL:\n%s\n", authURLString)
 		fmt.Fprintf(os.Stdout, "Code verifier (store securely):\n%s\n", codeVerifier)
+		fmt.Fprintf(os.Stdout, "State: %s (expires %s)\n", state, now.Add(ttl).Local().Format("15:04:05"))
 		return nil
 	},
 }
@@ -78,4 +95,5 @@ var urlCmd = &cobra.Command{
 func init() {
 	urlCmd.Flags().BoolVar(&urlJSON, "json", false, "Output machine-readable JSON")
 	urlCmd.Flags().StringVar(&urlRedirectURI, "redirect-uri", "", "Redirect URI to use in the authorization URL")
+	urlCmd.Flags().DurationVar(&urlTTL, "ttl", cli.DefaultPKCETTL, "How long the PKCE session can be exchanged (default from keycloak.pkce_ttl)")
 }
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	whoamiJSON     bool
+	whoamiNoVerify bool
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the identity and claims of the stored token",
+	Long: "Decodes the stored access and ID tokens locally, verifies their signatures against the provider's JWKS " +
+		"(cached like the discovery document) and shows subject, email, realm roles, audience and lifetimes, " +
+		"including when the refresh token expires. Tokens never leave the machine.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		tok, err := cli.LoadToken()
+		if err != nil {
+			return err
+		}
+		authURL, realm := tok.AuthURL, tok.Realm
+		if authURL == "" || realm == "" {
+			if authURL, realm, _, _, err = resolveAuthConfig(); err != nil {
+				return err
+			}
+		} else {
+			cli.UseIssuer(flagOrConfig("issuer", issuerFlag, "keycloak.issuer"), 0)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+		access := inspectJWT(ctx, "access token", tok.AccessToken, authURL, realm)
+		var id *jwtReport
+		if tok.IDToken != "" {
+			id = inspectJWT(ctx, "ID token", tok.IDToken, authURL, realm)
+		}
+		refresh := refreshExpiry(tok)
+
+		if whoamiJSON {
+			out := map[string]any{
+				"access_token": access,
+				"refresh":      refresh,
+			}
+			if id != nil {
+				out["id_token"] = id
+			}
+			b, _ := json.MarshalIndent(out, "", "  ")
+			fmt.Fprintln(os.Stdout, string(b))
+		} else if err := writeWhoami(os.Stdout, access, id, refresh); err != nil {
+			return err
+		}
+
+		for _, r := range []*jwtReport{access, id} {
+			if r != nil && r.Signature != "" && !strings.HasPrefix(r.Signature, "verified") && !whoamiNoVerify {
+				return fmt.Errorf("%s signature: %s", r.Kind, r.Signature)
+			}
+		}
+		return nil
+	},
+}
+
+// jwtReport is what whoami shows for one token.
+type jwtReport struct {
+	Kind      string         `json:"-"`
+	Error     string         `json:"error,omitempty"`
+	Signature string         `json:"signature,omitempty"`
+	Subject   string         `json:"sub,omitempty"`
+	Username  string         `json:"preferred_username,omitempty"`
+	Email     string         `json:"email,omitempty"`
+	Roles     []string       `json:"realm_roles,omitempty"`
+	Audience  []string       `json:"aud,omitempty"`
+	Issuer    string         `json:"iss,omitempty"`
+	IssuedAt  *time.Time     `json:"iat,omitempty"`
+	ExpiresAt *time.Time     `json:"exp,omitempty"`
+	Remaining string         `json:"remaining,omitempty"`
+	Claims    map[string]any `json:"claims,omitempty"`
+}
+
+func inspectJWT(ctx context.Context, kind, raw, authURL, realm string) *jwtReport {
+	r := &jwtReport{Kind: kind}
+	t, err := cli.ParseJWT(raw)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Subject = t.String("sub")
+	r.Username = t.String("preferred_username")
+	r.Email = t.String("email")
+	r.Roles = t.RealmRoles()
+	r.Audience = t.Strings("aud")
+	r.Issuer = t.String("iss")
+	r.Claims = t.Claims
+	if iat := t.Time("iat"); !iat.IsZero() {
+		r.IssuedAt = &iat
+	}
+	if exp := t.Time("exp"); !exp.IsZero() {
+		r.ExpiresAt = &exp
+		r.Remaining = remaining(exp)
+	}
+	if !whoamiNoVerify {
+		r.Signature = verifyJWT(ctx, t, authURL, realm)
+	}
+	return r
+}
+
+func verifyJWT(ctx context.Context, t *cli.JWT, authURL, realm string) string {
+	kid, _ := t.Header["kid"].(string)
+	alg, _ := t.Header["alg"].(string)
+	keys, err := cli.LoadJWKS(ctx, authURL, realm, kid)
+	if err != nil {
+		return "not verified: " + err.Error()
+	}
+	if err := t.Verify(keys); err != nil {
+		return "INVALID: " + err.Error()
+	}
+	if kid != "" {
+		return fmt.Sprintf("verified (%s, key %s)", alg, kid)
+	}
+	return fmt.Sprintf("verified (%s)", alg)
+}
+
+// refreshExpiry reports when the refresh token expires, from the
+// refresh_expires_in the token endpoint returned.
+func refreshExpiry(tok *cli.Token) map[string]any {
+	out := map[string]any{}
+	switch {
+	case tok.RefreshToken == "":
+		out["status"] = "none"
+	case tok.RefreshExpiresIn <= 0 || tok.ObtainedAt.IsZero():
+		// Keycloak reports 0 for offline tokens, which only expire when idle
+		// for the realm's offline session timeout.
+		out["status"] = "no fixed expiry (offline token)"
+	default:
+		exp := tok.ObtainedAt.Add(time.Duration(tok.RefreshExpiresIn) * time.Second)
+		out["expires_at"] = exp
+		out["status"] = remaining(exp)
+	}
+	return out
+}
+
+func remaining(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d <= 0 {
+		return fmt.Sprintf("expired %s ago", -d)
+	}
+	return "expires in " + d.String()
+}
+
+func writeWhoami(w io.Writer, access, id *jwtReport, refresh map[string]any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s\t%s\n", label, value)
+		}
+	}
+	when := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	if access.Error != "" {
+		row("Access token:", "not a JWT ("+access.Error+")")
+	} else {
+		row("Subject:", access.Subject)
+		row("Username:", access.Username)
+		row("Email:", access.Email)
+		row("Realm roles:", strings.Join(access.Roles, ", "))
+		row("Audience:", strings.Join(access.Audience, ", "))
+		row("Issuer:", access.Issuer)
+		row("Issued:", when(access.IssuedAt))
+		if access.ExpiresAt != nil {
+			row("Expires:", when(access.ExpiresAt)+" ("+access.Remaining+")")
+		}
+		row("Signature:", access.Signature)
+	}
+	if id != nil {
+		if id.Error != "" {
+			row("ID token:", "not a JWT ("+id.Error+")")
+		} else {
+			desc := "aud " + strings.Join(id.Audience, ", ")
+			if id.ExpiresAt != nil {
+				desc += ", " + id.Remaining
+			}
+			row("ID token:", desc)
+			row("ID signature:", id.Signature)
+		}
+	}
+	status, _ := refresh["status"].(string)
+	if exp, ok := refresh["expires_at"].(time.Time); ok {
+		status = exp.Local().Format("2006-01-02 15:04:05") + " (" + status + ")"
+	}
+	row("Refresh token:", status)
+	return tw.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(whoamiCmd)
+
+	whoamiCmd.Flags().BoolVar(&whoamiJSON, "json", false, "Output machine-readable JSON")
+	whoamiCmd.Flags().BoolVar(&whoamiNoVerify, "no-verify", false, "Only decode the tokens; skip signature verification")
+}
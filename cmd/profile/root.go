@@ -0,0 +1,276 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+	"github.com/zamedic/labradoc-cli/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var RootCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named profiles for accounts and environments",
+	Long: "Profiles bundle an API URL, Keycloak settings, an API key and separate token storage under a name. " +
+		"Select one with --profile, LABRADOC_PROFILE or labradoc profile use; \"default\" means the settings outside any profile.",
+	// Profile commands manage profiles.yaml themselves and must work even
+	// when the selected profile does not exist.
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		return config.ApplyTokenStore()
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		active := config.ProfileName(profiles)
+		rows := [][]string{{"", "NAME", "API URL", "AUTH URL", "REALM", "API KEY", "TOKEN"}}
+		rows = append(rows, []string{marker(active, cli.DefaultProfile), cli.DefaultProfile, "(config)", "(config)", "(config)", "-", tokenState(cli.DefaultProfile)})
+		for _, name := range profiles.Names() {
+			p := profiles.Profiles[name]
+			key := "-"
+			if cli.IsSealedSecret(p.APIToken) {
+				key = "encrypted"
+			} else if p.APIToken != "" {
+				key = "set"
+			}
+			rows = append(rows, []string{marker(active, name), name, orDash(p.APIURL), orDash(p.Keycloak.URL), orDash(p.Keycloak.Realm), key, tokenState(name)})
+		}
+		return writeTable(rows)
+	},
+}
+
+var useCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the current profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		name := args[0]
+		if name == cli.DefaultProfile {
+			profiles.Current = ""
+		} else {
+			if _, ok := profiles.Profiles[name]; !ok {
+				return fmt.Errorf("profile %q not found", name)
+			}
+			profiles.Current = name
+		}
+		if err := cli.SaveProfiles(profiles); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "Switched to profile %q.\n", name)
+		if env := os.Getenv("LABRADOC_PROFILE"); env != "" && env != name {
+			fmt.Fprintf(os.Stderr, "Note: LABRADOC_PROFILE=%s overrides the current profile in this shell.\n", env)
+		}
+		return nil
+	},
+}
+
+var (
+	addAPIURL   string
+	addAuthURL  string
+	addRealm    string
+	addClientID string
+	addIssuer   string
+	addAPIToken string
+	addUse      bool
+	addForce    bool
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a profile",
+	Long:  "Adds a profile. Unset values fall back to labrador.yaml, the environment and the built-in defaults.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := cli.ValidateProfileName(name); err != nil {
+			return err
+		}
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		if _, ok := profiles.Profiles[name]; ok && !addForce {
+			return fmt.Errorf("profile %q already exists (use --force to replace it)", name)
+		}
+		apiToken := strings.TrimSpace(addAPIToken)
+		if cli.ActiveTokenStore().Kind() == cli.StoreEncrypted {
+			if apiToken, err = cli.SealSecret(apiToken); err != nil {
+				return err
+			}
+		}
+		profiles.Profiles[name] = &cli.Profile{
+			APIURL:   strings.TrimSpace(addAPIURL),
+			APIToken: apiToken,
+			Keycloak: cli.KeycloakConfig{
+				URL:      strings.TrimSpace(addAuthURL),
+				Realm:    strings.TrimSpace(addRealm),
+				ClientID: strings.TrimSpace(addClientID),
+				Issuer:   strings.TrimSpace(addIssuer),
+			},
+		}
+		if addUse {
+			profiles.Current = name
+		}
+		if err := cli.SaveProfiles(profiles); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "Profile %q saved.\n", name)
+		return nil
+	},
+}
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a profile and its stored tokens",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		if _, ok := profiles.Profiles[name]; !ok {
+			return fmt.Errorf("profile %q not found", name)
+		}
+		delete(profiles.Profiles, name)
+		if profiles.Current == name {
+			profiles.Current = ""
+		}
+		if err := cli.SaveProfiles(profiles); err != nil {
+			return err
+		}
+		if err := cli.RemoveProfileData(name); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "Profile %q removed.\n", name)
+		return nil
+	},
+}
+
+var showSecrets bool
+
+var showCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show a profile (default: the active one)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := cli.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		name := config.ProfileName(profiles)
+		if len(args) == 1 {
+			name = args[0]
+		}
+		p := &cli.Profile{}
+		if name != cli.DefaultProfile {
+			var ok bool
+			if p, ok = profiles.Profiles[name]; !ok {
+				return fmt.Errorf("profile %q not found", name)
+			}
+		}
+		dir, err := cli.ProfileDir(name)
+		if err != nil {
+			return err
+		}
+		key := orDash(p.APIToken)
+		if cli.IsSealedSecret(p.APIToken) {
+			key = "(encrypted)"
+			if showSecrets {
+				if key, err = cli.OpenSecret(p.APIToken); err != nil {
+					return err
+				}
+			}
+		} else if p.APIToken != "" && !showSecrets {
+			key = maskSecret(p.APIToken)
+		}
+		rows := [][]string{
+			{"Name:", name},
+			{"Active:", fmt.Sprintf("%t", config.ProfileName(profiles) == name)},
+			{"API URL:", orDash(p.APIURL)},
+			{"Auth URL:", orDash(p.Keycloak.URL)},
+			{"Realm:", orDash(p.Keycloak.Realm)},
+			{"Client ID:", orDash(p.Keycloak.ClientID)},
+			{"Issuer:", orDash(p.Keycloak.Issuer)},
+			{"API key:", key},
+			{"Token:", tokenState(name)},
+			{"Storage:", dir},
+		}
+		return writeTable(rows)
+	},
+}
+
+func tokenState(profile string) string {
+	tok, err := cli.LoadProfileToken(profile)
+	if err != nil {
+		if cli.ProfileTokenForm(profile) == cli.StoreEncrypted {
+			return "encrypted"
+		}
+		return "-"
+	}
+	if tok.Expiry.IsZero() {
+		return "stored"
+	}
+	return "expires " + tok.Expiry.Local().Format("2006-01-02 15:04")
+}
+
+func marker(active, name string) string {
+	if active == name {
+		return "*"
+	}
+	return ""
+}
+
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8) + s[len(s)-4:]
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func writeTable(rows [][]string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+	RootCmd.AddCommand(useCmd)
+	RootCmd.AddCommand(addCmd)
+	RootCmd.AddCommand(removeCmd)
+	RootCmd.AddCommand(showCmd)
+
+	addCmd.Flags().StringVar(&addAPIURL, "api-url", "", "API base URL")
+	addCmd.Flags().StringVar(&addAuthURL, "auth-url", "", "Keycloak base URL")
+	addCmd.Flags().StringVar(&addRealm, "realm", "", "Keycloak realm")
+	addCmd.Flags().StringVar(&addClientID, "client-id", "", "OAuth client ID")
+	addCmd.Flags().StringVar(&addIssuer, "issuer", "", "OIDC issuer URL for endpoint discovery")
+	addCmd.Flags().StringVar(&addAPIToken, "api-token", "", "API key for this profile")
+	addCmd.Flags().BoolVar(&addUse, "use", false, "Make the new profile current")
+	addCmd.Flags().BoolVar(&addForce, "force", false, "Replace an existing profile")
+	showCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Print the API key unmasked")
+}
@@ -3,12 +3,27 @@ package cmd
 import (
 	"github.com/zamedic/labradoc-cli/cmd/api"
 	"github.com/zamedic/labradoc-cli/cmd/auth"
+	"github.com/zamedic/labradoc-cli/cmd/profile"
+	"github.com/zamedic/labradoc-cli/internal/config"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+var profileFlag string
+
 var RootCmd = cobra.Command{
 	Use: "labradoc-cli",
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		if err := config.ApplyTokenStore(); err != nil {
+			return err
+		}
+		if _, err := config.ApplyProfile(); err != nil {
+			return err
+		}
+		config.ApplyOIDC()
+		return nil
+	},
 }
 
 func Execute() {
@@ -16,6 +31,10 @@ func Execute() {
 }
 
 func init() {
+	RootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Profile to use (default from LABRADOC_PROFILE or labradoc profile use)")
+	viper.BindPFlag("profile", RootCmd.PersistentFlags().Lookup("profile"))
+
 	RootCmd.AddCommand(auth.RootCmd)
 	RootCmd.AddCommand(api.RootCmd)
+	RootCmd.AddCommand(profile.RootCmd)
 }
@@ -0,0 +1,383 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	agentSocketName = "agent.sock"
+	agentRetryDelay = 10 * time.Second
+	agentMaxSleep   = time.Minute
+
+	// DefaultAgentRefreshBefore is how long before expiry the agent renews
+	// the access token.
+	DefaultAgentRefreshBefore = 2 * time.Minute
+)
+
+// ErrAgentNotRunning means no token agent listens on the profile's socket.
+var ErrAgentNotRunning = errors.New("token agent not running")
+
+// AgentStatus is what GET /status on the agent socket returns.
+type AgentStatus struct {
+	PID         int       `json:"pid"`
+	Profile     string    `json:"profile"`
+	Started     time.Time `json:"started"`
+	Expiry      time.Time `json:"expiry,omitempty"`
+	Grant       string    `json:"grant,omitempty"`
+	Refreshes   int       `json:"refreshes"`
+	LastRefresh time.Time `json:"last_refresh,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// AgentSocketPath returns the token agent's socket in the active profile's
+// directory.
+func AgentSocketPath() (string, error) {
+	dir, err := ProfileDir(activeProfile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, agentSocketName), nil
+}
+
+func agentClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// agentCall sends one request to the agent of the active profile and decodes
+// the JSON reply into out.
+func agentCall(ctx context.Context, method, path string, out any) error {
+	socket, err := AgentSocketPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(socket); err != nil {
+		return ErrAgentNotRunning
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://agent"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := agentClient(socket).Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return ErrAgentNotRunning
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("token agent: %s", e.Error)
+		}
+		return fmt.Errorf("token agent: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// AgentToken asks the running agent for a fresh access token. It returns
+// ErrAgentNotRunning when there is no agent for the active profile.
+func AgentToken(ctx context.Context) (*Token, error) {
+	var tok Token
+	if err := agentCall(ctx, http.MethodGet, "/token", &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+// AgentStatusInfo returns the running agent's status.
+func AgentStatusInfo(ctx context.Context) (*AgentStatus, error) {
+	var st AgentStatus
+	if err := agentCall(ctx, http.MethodGet, "/status", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// StopAgent asks the running agent to shut down.
+func StopAgent(ctx context.Context) error {
+	return agentCall(ctx, http.MethodPost, "/stop", nil)
+}
+
+// RenewToken gets a new access token for tok: by refresh token, or by the
+// client credentials grant for service accounts. The result keeps the API URL
+// and, when the server did not rotate it, the refresh token.
+func RenewToken(ctx context.Context, tok *Token) (*Token, error) {
+	if tok.Grant == GrantClientCredentials {
+		return reacquireClientToken(ctx, tok)
+	}
+	if tok.RefreshToken == "" {
+		return nil, errors.New("no refresh_token available")
+	}
+	fresh, err := RefreshToken(ctx, tok.AuthURL, tok.Realm, tok.ClientID, tok.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = tok.RefreshToken
+	}
+	fresh.APIURL = tok.APIURL
+	return fresh, nil
+}
+
+// tokenAgent owns the token of one profile and renews it ahead of expiry.
+type tokenAgent struct {
+	refreshBefore time.Duration
+	wake          chan struct{}
+
+	mu     sync.Mutex
+	tok    *Token
+	stored fileStamp
+	status AgentStatus
+}
+
+// fileStamp tells versions of the token file apart without reading it, which
+// for the encrypted store would mean deriving the key again.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s fileStamp) same(o fileStamp) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+// tokenFileStamp returns the stamp of the newest stored token file, plain or
+// encrypted.
+func tokenFileStamp() fileStamp {
+	path, err := tokenPath()
+	if err != nil {
+		return fileStamp{}
+	}
+	var st fileStamp
+	for _, p := range []string{path, path + encryptedSuffix} {
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(st.modTime) {
+			st = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return st
+}
+
+// RunAgent serves fresh access tokens for the active profile on its socket
+// until ctx is done or a client sends /stop. The socket is only accessible to
+// the current user. Renewed tokens are saved to the token store as well, so
+// processes that do not use the agent see them too.
+func RunAgent(ctx context.Context, refreshBefore time.Duration) error {
+	stored := tokenFileStamp()
+	tok, err := LoadToken()
+	if err != nil {
+		return err
+	}
+	socket, err := AgentSocketPath()
+	if err != nil {
+		return err
+	}
+	if err := ensureDir(socket); err != nil {
+		return err
+	}
+	if _, err := AgentStatusInfo(ctx); err == nil {
+		return fmt.Errorf("a token agent is already running on %s", socket)
+	}
+	// Whatever is left at the path is a stale socket from an agent that
+	// did not shut down cleanly.
+	_ = os.Remove(socket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+	defer os.Remove(socket)
+
+	if refreshBefore <= 0 {
+		refreshBefore = DefaultAgentRefreshBefore
+	}
+	a := &tokenAgent{
+		refreshBefore: refreshBefore,
+		wake:          make(chan struct{}, 1),
+		tok:           tok,
+		stored:        stored,
+		status: AgentStatus{
+			PID:     os.Getpid(),
+			Profile: ActiveProfile(),
+			Started: time.Now().UTC(),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", a.handleToken)
+	mux.HandleFunc("GET /status", a.handleStatus)
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, _ *http.Request) {
+		writeAgentJSON(w, http.StatusOK, map[string]string{"status": "stopping"})
+		cancel()
+	})
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	go a.refreshLoop(ctx)
+
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (a *tokenAgent) handleToken(w http.ResponseWriter, r *http.Request) {
+	tok, err := a.current(r.Context())
+	if err != nil {
+		writeAgentJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	// Only what a client needs to make requests leaves the agent.
+	writeAgentJSON(w, http.StatusOK, Token{
+		AccessToken: tok.AccessToken,
+		TokenType:   tok.TokenType,
+		Expiry:      tok.Expiry,
+		Scope:       tok.Scope,
+		APIURL:      tok.APIURL,
+		Grant:       tok.Grant,
+	})
+}
+
+func (a *tokenAgent) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	a.mu.Lock()
+	st := a.status
+	st.Expiry = a.tok.Expiry
+	st.Grant = a.tok.Grant
+	a.mu.Unlock()
+	writeAgentJSON(w, http.StatusOK, st)
+}
+
+// current returns a token that has not expired, renewing it first when the
+// refresh loop has not managed to.
+func (a *tokenAgent) current(ctx context.Context) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.adoptStored()
+	if !a.tok.Expired() {
+		return a.tok, nil
+	}
+	if err := a.renew(ctx); err != nil {
+		return nil, err
+	}
+	return a.tok, nil
+}
+
+// adoptStored switches to the stored token when it is newer than the one the
+// agent holds, for example after a new auth login. The file is only read
+// again when it has changed.
+func (a *tokenAgent) adoptStored() {
+	stamp := tokenFileStamp()
+	if stamp.same(a.stored) {
+		return
+	}
+	a.stored = stamp
+	stored, err := LoadToken()
+	if err != nil || !stored.ObtainedAt.After(a.tok.ObtainedAt) {
+		return
+	}
+	a.tok = stored
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// renew must be called with a.mu held.
+func (a *tokenAgent) renew(ctx context.Context) error {
+	fresh, renewed, err := UpdateToken(a.tok, func(cur *Token) (*Token, error) {
+		return RenewToken(ctx, cur)
+	})
+	if err != nil {
+		a.status.LastError = err.Error()
+		return err
+	}
+	a.tok = fresh
+	if renewed {
+		a.status.Refreshes++
+		a.status.LastRefresh = time.Now().UTC()
+	}
+	a.status.LastError = ""
+	return nil
+}
+
+// leadTime is how long before expiry the token is renewed: refreshBefore,
+// but at most half the token's lifetime so short-lived tokens are not renewed
+// again straight after every refresh. Must be called with a.mu held.
+func (a *tokenAgent) leadTime() time.Duration {
+	if a.tok.ObtainedAt.IsZero() {
+		return a.refreshBefore
+	}
+	return min(a.refreshBefore, a.tok.Expiry.Sub(a.tok.ObtainedAt)/2)
+}
+
+func (a *tokenAgent) refreshLoop(ctx context.Context) {
+	for {
+		a.mu.Lock()
+		a.adoptStored()
+		wait := agentMaxSleep
+		if !a.tok.Expiry.IsZero() {
+			due := time.Until(a.tok.Expiry.Add(-a.leadTime()))
+			if due <= 0 {
+				rctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				err := a.renew(rctx)
+				cancel()
+				due = agentRetryDelay
+				if err == nil {
+					due = time.Until(a.tok.Expiry.Add(-a.leadTime()))
+				}
+			}
+			wait = min(max(due, time.Second), agentMaxSleep)
+		}
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+func writeAgentJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
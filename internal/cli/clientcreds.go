@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// GrantClientCredentials marks tokens obtained for a service account in
+	// Token.Grant. They have no refresh token and are re-acquired with the
+	// secret from Token.ClientSecretFile, or LABRADOC_CLIENT_SECRET when that
+	// is empty.
+	GrantClientCredentials = "client_credentials"
+
+	// EnvClientSecret holds the client secret for client-credentials logins.
+	EnvClientSecret = "LABRADOC_CLIENT_SECRET"
+
+	// tokenExpirySkew re-acquires tokens slightly before they expire so a
+	// request does not race the expiry.
+	tokenExpirySkew = 30 * time.Second
+)
+
+// ClientCredentialsToken requests a token for a confidential client with the
+// client credentials grant.
+func ClientCredentialsToken(ctx context.Context, baseURL, realm, clientID, clientSecret, scope string) (*Token, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("missing client_id or client secret")
+	}
+	endpoint, err := TokenEndpoint(baseURL, realm)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	status, body, err := postForm(ctx, endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, fmt.Errorf("client credentials login failed: %s", strings.TrimSpace(string(body)))
+	}
+	tok, err := parseToken(body, baseURL, realm, clientID)
+	if err != nil {
+		return nil, err
+	}
+	tok.Grant = GrantClientCredentials
+	return tok, nil
+}
+
+// ReadClientSecret returns the client secret from a file, or from
+// LABRADOC_CLIENT_SECRET when path is empty.
+func ReadClientSecret(path string) (string, error) {
+	if path == "" {
+		if v := strings.TrimSpace(os.Getenv(EnvClientSecret)); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("missing client secret (use --client-secret-file or %s)", EnvClientSecret)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read client secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("client secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// Expired reports whether the access token has expired or is about to.
+func (t *Token) Expired() bool {
+	return !t.Expiry.IsZero() && time.Now().Add(tokenExpirySkew).After(t.Expiry)
+}
+
+// ReacquireClientToken requests a new client-credentials token with the
+// settings recorded in an earlier one and saves it. If another process has
+// replaced old in the meantime, its token is used instead.
+func ReacquireClientToken(ctx context.Context, old *Token) (*Token, error) {
+	tok, _, err := UpdateToken(old, func(cur *Token) (*Token, error) {
+		return reacquireClientToken(ctx, cur)
+	})
+	return tok, err
+}
+
+func reacquireClientToken(ctx context.Context, old *Token) (*Token, error) {
+	if old.Grant != GrantClientCredentials {
+		return nil, errors.New("token was not obtained with client credentials")
+	}
+	secret, err := ReadClientSecret(old.ClientSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := ClientCredentialsToken(ctx, old.AuthURL, old.Realm, old.ClientID, secret, old.Scope)
+	if err != nil {
+		return nil, err
+	}
+	tok.ClientSecretFile = old.ClientSecretFile
+	tok.APIURL = old.APIURL
+	if tok.Scope == "" {
+		tok.Scope = old.Scope
+	}
+	return tok, nil
+}
+
+// LoadUsableToken returns a fresh token from the token agent when one runs for
+// the active profile. Otherwise it loads the stored token and, for service
+// accounts, acquires a new one when it has expired.
+func LoadUsableToken(ctx context.Context) (*Token, error) {
+	if tok, err := AgentToken(ctx); !errors.Is(err, ErrAgentNotRunning) {
+		return tok, err
+	}
+	tok, err := LoadToken()
+	if err != nil {
+		return nil, err
+	}
+	return renewClientToken(ctx, tok)
+}
+
+// UsableToken is LoadUsableToken for a stored token the caller has already
+// loaded, so the token file is not read again.
+func UsableToken(ctx context.Context, tok *Token) (*Token, error) {
+	if fresh, err := AgentToken(ctx); !errors.Is(err, ErrAgentNotRunning) {
+		return fresh, err
+	}
+	return renewClientToken(ctx, tok)
+}
+
+func renewClientToken(ctx context.Context, tok *Token) (*Token, error) {
+	if tok.Grant == GrantClientCredentials && tok.Expired() {
+		return ReacquireClientToken(ctx, tok)
+	}
+	return tok, nil
+}
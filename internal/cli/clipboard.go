@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CopyOSC52 asks the terminal to put text on the system clipboard using the
+// OSC 52 escape sequence. This works over SSH as long as the terminal
+// supports it; inside tmux or screen the sequence is wrapped so it reaches
+// the outer terminal.
+func CopyOSC52(text string) error {
+	var w io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		w = tty
+	}
+	_, err := io.WriteString(w, osc52Sequence(text))
+	return err
+}
+
+func osc52Sequence(text string) string {
+	seq := fmt.Sprintf("\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return "\x1bP" + seq + "\x1b\\"
+	}
+	return seq
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ConfigFileValue returns a top-level scalar of a YAML config file such as
+// labrador.yaml, and whether the key is present.
+func ConfigFileValue(path, key string) (string, bool, error) {
+	_, node, err := readConfigNode(path, key)
+	if err != nil || node == nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(node.Value), true, nil
+}
+
+// SetConfigFileValue replaces a top-level scalar of a YAML config file in
+// place, keeping its comments, key order and file mode.
+func SetConfigFileValue(path, key, value string) error {
+	doc, node, err := readConfigNode(path, key)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return errors.New(path + " has no " + key)
+	}
+	node.Value, node.Style = value, 0
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), info.Mode().Perm())
+}
+
+func readConfigNode(path, key string) (*yaml.Node, *yaml.Node, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return &doc, nil, nil
+	}
+	root := doc.Content[0]
+	var node *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			node = root.Content[i+1]
+		}
+	}
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return &doc, nil, nil
+	}
+	return &doc, node, nil
+}
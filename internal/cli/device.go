@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDeviceInterval = 5 * time.Second
+	slowDownIncrement     = 5 * time.Second
+)
+
+// DeviceAuthorization is the device authorization response of RFC 8628.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval,omitempty"`
+}
+
+// oauthError is the error body of the token and device endpoints.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e oauthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+// StartDeviceAuthorization requests a device and user code for a headless
+// login.
+func StartDeviceAuthorization(ctx context.Context, baseURL, realm, clientID, scope string) (*DeviceAuthorization, error) {
+	endpoint, err := DeviceEndpoint(baseURL, realm)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	status, body, err := postForm(ctx, endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, fmt.Errorf("device authorization failed: %s", strings.TrimSpace(string(body)))
+	}
+	var da DeviceAuthorization
+	if err := json.Unmarshal(body, &da); err != nil {
+		return nil, err
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, errors.New("device authorization response missing device_code, user_code or verification_uri")
+	}
+	return &da, nil
+}
+
+// PollDeviceToken polls the token endpoint until the user approves or denies
+// the device login or the device code expires. authorization_pending keeps
+// polling and slow_down adds five seconds to the interval.
+func PollDeviceToken(ctx context.Context, baseURL, realm, clientID string, da *DeviceAuthorization) (*Token, error) {
+	endpoint, err := TokenEndpoint(baseURL, realm)
+	if err != nil {
+		return nil, err
+	}
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+	if da.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(da.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	form.Set("client_id", clientID)
+	form.Set("device_code", da.DeviceCode)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("device code expired before the login was approved")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		status, body, err := postForm(ctx, endpoint, form)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return nil, err
+		}
+		if status < 400 {
+			return parseToken(body, baseURL, realm, clientID)
+		}
+		var oerr oauthError
+		if json.Unmarshal(body, &oerr) != nil || oerr.Code == "" {
+			return nil, fmt.Errorf("device token request failed: %s", strings.TrimSpace(string(body)))
+		}
+		switch oerr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrement
+		case "access_denied":
+			return nil, fmt.Errorf("login denied: %w", oerr)
+		case "expired_token":
+			return nil, fmt.Errorf("device code expired: %w", oerr)
+		default:
+			return nil, fmt.Errorf("device token request failed: %w", oerr)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryPath    = "/.well-known/openid-configuration"
+	discoveryTimeout = 10 * time.Second
+	cacheDirName     = "cache"
+
+	// DefaultDiscoveryTTL is how long a fetched discovery document is used
+	// before it is fetched again.
+	DefaultDiscoveryTTL = 24 * time.Hour
+)
+
+// ProviderMetadata holds the OIDC endpoints the CLI uses, from the issuer's
+// discovery document or built from Keycloak's URL layout.
+type ProviderMetadata struct {
+	Issuer                      string    `json:"issuer"`
+	AuthorizationEndpoint       string    `json:"authorization_endpoint"`
+	TokenEndpoint               string    `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string    `json:"device_authorization_endpoint,omitempty"`
+	RevocationEndpoint          string    `json:"revocation_endpoint,omitempty"`
+	EndSessionEndpoint          string    `json:"end_session_endpoint,omitempty"`
+	JWKSURI                     string    `json:"jwks_uri,omitempty"`
+	UserinfoEndpoint            string    `json:"userinfo_endpoint,omitempty"`
+	FetchedAt                   time.Time `json:"fetched_at,omitempty"`
+	// Source is "discovery", "cache" or "fallback"; not part of the document.
+	Source string `json:"-"`
+}
+
+var (
+	configuredIssuer string
+	discoveryTTL     = DefaultDiscoveryTTL
+
+	providerMu    sync.Mutex
+	providerCache = map[string]*ProviderMetadata{}
+)
+
+// UseIssuer sets the OIDC issuer URL whose discovery document supplies the
+// endpoints, and how long the document is cached. An empty issuer means
+// <auth-url>/realms/<realm>; ttl <= 0 keeps the default.
+func UseIssuer(issuer string, ttl time.Duration) {
+	configuredIssuer = strings.TrimRight(strings.TrimSpace(issuer), "/")
+	if ttl > 0 {
+		discoveryTTL = ttl
+	}
+}
+
+// IssuerURL returns the configured issuer, or the Keycloak realm URL.
+func IssuerURL(baseURL, realm string) string {
+	if configuredIssuer != "" {
+		return configuredIssuer
+	}
+	return strings.TrimRight(baseURL, "/") + "/realms/" + realm
+}
+
+// Provider returns the endpoints for a Keycloak base URL and realm. It uses
+// the issuer's discovery document, fetched at most once per TTL and cached in
+// the config directory. When discovery fails it falls back to a stale cached
+// document, then to Keycloak's /realms/<realm>/protocol/openid-connect paths.
+func Provider(baseURL, realm string) *ProviderMetadata {
+	issuer := IssuerURL(baseURL, realm)
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if p, ok := providerCache[issuer]; ok {
+		return p
+	}
+	p := resolveProvider(issuer, baseURL, realm)
+	providerCache[issuer] = p
+	return p
+}
+
+// RefreshProvider discards the cached discovery document and fetches it
+// again.
+func RefreshProvider(ctx context.Context, baseURL, realm string) (*ProviderMetadata, error) {
+	issuer := IssuerURL(baseURL, realm)
+	p, err := fetchDiscovery(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	_ = saveDiscovery(issuer, p)
+	p.Source = "discovery"
+	providerMu.Lock()
+	providerCache[issuer] = p
+	providerMu.Unlock()
+	return p, nil
+}
+
+func resolveProvider(issuer, baseURL, realm string) *ProviderMetadata {
+	cached, _ := loadDiscovery(issuer)
+	if cached != nil && time.Since(cached.FetchedAt) < discoveryTTL {
+		cached.Source = "cache"
+		return cached
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	if p, err := fetchDiscovery(ctx, issuer); err == nil {
+		_ = saveDiscovery(issuer, p)
+		p.Source = "discovery"
+		return p
+	}
+	if cached != nil {
+		cached.Source = "cache"
+		return cached
+	}
+	return keycloakProvider(baseURL, realm)
+}
+
+// keycloakProvider builds the endpoints from Keycloak's URL layout.
+func keycloakProvider(baseURL, realm string) *ProviderMetadata {
+	issuer := strings.TrimRight(baseURL, "/") + "/realms/" + realm
+	oidc := issuer + "/protocol/openid-connect"
+	return &ProviderMetadata{
+		Issuer:                      issuer,
+		AuthorizationEndpoint:       oidc + "/auth",
+		TokenEndpoint:               oidc + "/token",
+		DeviceAuthorizationEndpoint: oidc + "/auth/device",
+		RevocationEndpoint:          oidc + "/revoke",
+		EndSessionEndpoint:          oidc + "/logout",
+		JWKSURI:                     oidc + "/certs",
+		UserinfoEndpoint:            oidc + "/userinfo",
+		Source:                      "fallback",
+	}
+}
+
+func fetchDiscovery(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+discoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("oidc discovery failed: %s", resp.Status)
+	}
+	var p ProviderMetadata
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	// OpenID Connect Discovery 1.0, section 4.3: the document must be for
+	// the issuer it was requested from.
+	if !sameIssuer(p.Issuer, issuer) {
+		return nil, fmt.Errorf("oidc discovery: document at %s is for issuer %q", issuer, p.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery: document at %s lacks authorization or token endpoint", issuer)
+	}
+	p.FetchedAt = time.Now().UTC()
+	return &p, nil
+}
+
+func sameIssuer(a, b string) bool {
+	return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
+
+func discoveryCachePath(issuer string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(issuer))
+	return filepath.Join(dir, cacheDirName, "oidc-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func loadDiscovery(issuer string) (*ProviderMetadata, error) {
+	path, err := discoveryCachePath(issuer)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p ProviderMetadata
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	if !sameIssuer(p.Issuer, issuer) {
+		return nil, fmt.Errorf("cached discovery document is for issuer %q", p.Issuer)
+	}
+	return &p, nil
+}
+
+func saveDiscovery(issuer string, p *ProviderMetadata) error {
+	path, err := discoveryCachePath(issuer)
+	if err != nil {
+		return err
+	}
+	if err := ensureDir(path); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, 0o600)
+}
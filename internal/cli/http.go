@@ -44,3 +44,33 @@ func DoRequest(ctx context.Context, method, path string, body io.Reader, opts Re
 	}
 	return client.Do(req)
 }
+
+// GetBytes performs a GET and returns the response body, turning HTTP error
+// statuses into errors that include the server's message.
+func GetBytes(ctx context.Context, path string, opts RequestOptions) ([]byte, error) {
+	resp, err := DoRequest(ctx, http.MethodGet, path, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(b))}
+	}
+	return b, nil
+}
+
+// HTTPError is returned for HTTP error statuses so callers can react to
+// specific codes such as 404.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("request failed: %s: %s", e.Status, e.Body)
+}
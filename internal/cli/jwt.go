@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// JWT is a decoded JSON Web Token. Decoding does not verify it; call Verify.
+type JWT struct {
+	Header map[string]any
+	Claims map[string]any
+
+	signingInput string
+	signature    []byte
+}
+
+// ParseJWT decodes the header and claims of a compact JWT.
+func ParseJWT(raw string) (*JWT, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT (expected three dot-separated parts)")
+	}
+	t := &JWT{signingInput: parts[0] + "." + parts[1]}
+	for i, target := range []*map[string]any{&t.Header, &t.Claims} {
+		b, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("decode JWT: %w", err)
+		}
+		if err := json.Unmarshal(b, target); err != nil {
+			return nil, fmt.Errorf("decode JWT: %w", err)
+		}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode JWT signature: %w", err)
+	}
+	t.signature = sig
+	return t, nil
+}
+
+// String returns a string claim, or "".
+func (t *JWT) String(key string) string {
+	s, _ := t.Claims[key].(string)
+	return s
+}
+
+// Time returns a NumericDate claim such as exp or iat.
+func (t *JWT) Time(key string) time.Time {
+	if f, ok := t.Claims[key].(float64); ok {
+		return time.Unix(int64(f), 0)
+	}
+	return time.Time{}
+}
+
+// Strings returns a claim that may be a single string or a list of strings,
+// such as aud.
+func (t *JWT) Strings(key string) []string {
+	return stringList(t.Claims[key])
+}
+
+// RealmRoles returns Keycloak's realm_access.roles claim.
+func (t *JWT) RealmRoles() []string {
+	access, _ := t.Claims["realm_access"].(map[string]any)
+	return stringList(access["roles"])
+}
+
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Verify checks the signature against the key set, matching on kid when the
+// token names one.
+func (t *JWT) Verify(keys *JWKS) error {
+	alg, _ := t.Header["alg"].(string)
+	kid, _ := t.Header["kid"].(string)
+	key := keys.find(kid)
+	if key == nil {
+		return fmt.Errorf("no signing key %q in the JWKS", kid)
+	}
+	if key.Alg != "" && key.Alg != alg {
+		return fmt.Errorf("token alg %s does not match key alg %s", alg, key.Alg)
+	}
+	return verifySignature(alg, key, []byte(t.signingInput), t.signature)
+}
+
+// JWK is one JSON Web Key; only RSA and EC signing keys are used.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys      []JWK     `json:"keys"`
+	FetchedAt time.Time `json:"fetched_at,omitempty"`
+}
+
+func (s *JWKS) find(kid string) *JWK {
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if k.Use == "enc" {
+			continue
+		}
+		if kid == "" || k.Kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+func (s *JWKS) has(kid string) bool {
+	return s.find(kid) != nil
+}
+
+// LoadJWKS returns the provider's signing keys, cached in the config
+// directory like the discovery document. The cache is refreshed when it is
+// older than the discovery TTL or lacks the key ID a token asks for.
+func LoadJWKS(ctx context.Context, baseURL, realm, kid string) (*JWKS, error) {
+	endpoint, err := JWKSEndpoint(baseURL, realm)
+	if err != nil {
+		return nil, err
+	}
+	path, err := jwksCachePath(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var cached *JWKS
+	if b, err := os.ReadFile(path); err == nil {
+		var s JWKS
+		if json.Unmarshal(b, &s) == nil {
+			cached = &s
+		}
+	}
+	if cached != nil && time.Since(cached.FetchedAt) < discoveryTTL && cached.has(kid) {
+		return cached, nil
+	}
+	fresh, err := fetchJWKS(ctx, endpoint)
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	if err := ensureDir(path); err == nil {
+		if b, err := json.MarshalIndent(fresh, "", "  "); err == nil {
+			_ = writeFileAtomic(path, b, 0o600)
+		}
+	}
+	return fresh, nil
+}
+
+func fetchJWKS(ctx context.Context, endpoint string) (*JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetch jwks: %s", resp.Status)
+	}
+	var s JWKS
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	s.FetchedAt = time.Now().UTC()
+	return &s, nil
+}
+
+func jwksCachePath(endpoint string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(dir, cacheDirName, "jwks-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func verifySignature(alg string, key *JWK, input, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, err := key.rsaKey()
+		if err != nil {
+			return err
+		}
+		if alg[:2] == "PS" {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+		if err != nil {
+			return errors.New("signature does not match")
+		}
+		return nil
+	case "ES":
+		pub, err := key.ecKey()
+		if err != nil {
+			return err
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("signature does not match")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature does not match")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported alg %q", alg)
+}
+
+func (k *JWK) rsaKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("key %q is %s, not RSA", k.Kid, k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (k *JWK) ecKey() (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" {
+		return nil, fmt.Errorf("key %q is %s, not EC", k.Kid, k.Kty)
+	}
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) > size || len(y) > size {
+		return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(x):1+size], x)
+	copy(point[1+2*size-len(y):], y)
+	return ecdsa.ParseUncompressedPublicKey(curve, point)
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package cli
+
+import "os"
+
+// Platforms without flock or LockFileEx only get atomic writes.
+func tryLock(*os.File) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
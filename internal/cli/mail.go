@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type MailMessage struct {
+	Source      string
+	MessageID   string
+	From        string
+	Subject     string
+	Date        time.Time
+	TextBody    []byte
+	HTMLBody    []byte
+	Attachments []MailPart
+}
+
+type MailPart struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	// Inline is set for parts referenced from the HTML body by Content-ID,
+	// such as logos in signatures.
+	Inline bool
+}
+
+var mailWordDecoder = mime.WordDecoder{}
+
+// WalkMail calls fn for every message found at path, which may be a single
+// .eml file, an mbox file or a Maildir folder (with cur/ and new/). In mbox
+// files and Maildirs a message that cannot be parsed is reported on stderr and
+// skipped.
+func WalkMail(path string, fn func(MailMessage) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return walkMaildir(path, fn)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(5)
+	if string(head) == "From " || strings.EqualFold(filepath.Ext(path), ".mbox") {
+		return walkMbox(path, br, fn)
+	}
+	msg, err := ParseMail(br)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	msg.Source = path
+	return fn(*msg)
+}
+
+func walkMaildir(dir string, fn func(MailMessage) error) error {
+	var paths []string
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				paths = append(paths, filepath.Join(dir, sub, e.Name()))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s: not a Maildir (no messages in cur/ or new/)", dir)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		msg, err := ParseMail(bytes.NewReader(b))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skip %s: %v\n", p, err)
+			continue
+		}
+		msg.Source = p
+		if err := fn(*msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkMbox splits an mboxrd/mboxo file on "From " separator lines and
+// un-escapes ">From " quoting in message bodies.
+func walkMbox(path string, r *bufio.Reader, fn func(MailMessage) error) error {
+	var buf bytes.Buffer
+	n := 0
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		n++
+		msg, err := ParseMail(bytes.NewReader(buf.Bytes()))
+		buf.Reset()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skip %s message %d: %v\n", path, n, err)
+			return nil
+		}
+		msg.Source = fmt.Sprintf("%s#%d", path, n)
+		return fn(*msg)
+	}
+	prevBlank := true
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if prevBlank && bytes.HasPrefix(line, []byte("From ")) {
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+			} else {
+				if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+					line = line[1:]
+				}
+				buf.Write(line)
+			}
+			prevBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ParseMail reads a single RFC 5322 message and collects its text and HTML
+// bodies and attachments.
+func ParseMail(r io.Reader) (*MailMessage, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	msg := &MailMessage{
+		MessageID: strings.Trim(m.Header.Get("Message-Id"), "<> "),
+		From:      decodeHeader(m.Header.Get("From")),
+		Subject:   decodeHeader(m.Header.Get("Subject")),
+	}
+	if d, err := m.Header.Date(); err == nil {
+		msg.Date = d
+	}
+	if err := collectParts(msg, textproto.MIMEHeader(m.Header), m.Body); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func collectParts(msg *MailMessage, header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := collectParts(msg, p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	dispType, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(dispParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	if filename != "" || dispType == "attachment" {
+		if filename != "" {
+			filename = filepath.Base(filepath.FromSlash(filename))
+		}
+		msg.Attachments = append(msg.Attachments, MailPart{
+			Filename:    filename,
+			ContentType: mediaType,
+			Data:        data,
+			Inline:      dispType != "attachment" && header.Get("Content-Id") != "",
+		})
+		return nil
+	}
+	switch mediaType {
+	case "text/plain":
+		if msg.TextBody == nil {
+			msg.TextBody = data
+		}
+	case "text/html":
+		if msg.HTMLBody == nil {
+			msg.HTMLBody = data
+		}
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+func decodeHeader(s string) string {
+	if d, err := mailWordDecoder.DecodeHeader(s); err == nil {
+		return d
+	}
+	return s
+}
@@ -26,26 +26,42 @@ func ExchangeCode(ctx context.Context, baseURL, realm, clientID, code, redirectU
 	form.Set("redirect_uri", redirectURI)
 	form.Set("code_verifier", codeVerifier)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	status, body, err := postForm(ctx, endpoint, form)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
+	if status >= 400 {
+		return nil, fmt.Errorf("token exchange failed: %s", strings.TrimSpace(string(body)))
+	}
+	return parseToken(body, baseURL, realm, clientID)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+func RefreshToken(ctx context.Context, baseURL, realm, clientID, refreshToken string) (*Token, error) {
+	if refreshToken == "" {
+		return nil, fmt.Errorf("missing refresh_token")
+	}
+	endpoint, err := TokenEndpoint(baseURL, realm)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", clientID)
+	form.Set("refresh_token", refreshToken)
 
-	body, err := io.ReadAll(resp.Body)
+	status, body, err := postForm(ctx, endpoint, form)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("token exchange failed: %s", strings.TrimSpace(string(body)))
+	if status >= 400 {
+		return nil, fmt.Errorf("token refresh failed: %s", strings.TrimSpace(string(body)))
 	}
+	return parseToken(body, baseURL, realm, clientID)
+}
+
+// parseToken decodes a token endpoint response and records where the token
+// came from.
+func parseToken(body []byte, baseURL, realm, clientID string) (*Token, error) {
 	var tok Token
 	if err := json.Unmarshal(body, &tok); err != nil {
 		return nil, err
@@ -62,51 +78,66 @@ func ExchangeCode(ctx context.Context, baseURL, realm, clientID, code, redirectU
 	return &tok, nil
 }
 
-func RefreshToken(ctx context.Context, baseURL, realm, clientID, refreshToken string) (*Token, error) {
-	if refreshToken == "" {
-		return nil, fmt.Errorf("missing refresh_token")
-	}
-	endpoint, err := TokenEndpoint(baseURL, realm)
-	if err != nil {
-		return nil, err
-	}
-	form := url.Values{}
-	form.Set("grant_type", "refresh_token")
-	form.Set("client_id", clientID)
-	form.Set("refresh_token", refreshToken)
-
+func postForm(ctx context.Context, endpoint string, form url.Values) (int, []byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("token refresh failed: %s", strings.TrimSpace(string(body)))
+	return resp.StatusCode, body, nil
+}
+
+// RevokeToken revokes a token at the provider's revocation endpoint
+// (RFC 7009). hint is "refresh_token" or "access_token".
+func RevokeToken(ctx context.Context, baseURL, realm, clientID, token, hint string) error {
+	endpoint, err := RevocationEndpoint(baseURL, realm)
+	if err != nil {
+		return err
 	}
-	var tok Token
-	if err := json.Unmarshal(body, &tok); err != nil {
-		return nil, err
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	form.Set("token", token)
+	if hint != "" {
+		form.Set("token_type_hint", hint)
 	}
-	if tok.ObtainedAt.IsZero() {
-		tok.ObtainedAt = time.Now().UTC()
+	status, body, err := postForm(ctx, endpoint, form)
+	if err != nil {
+		return err
 	}
-	if tok.Expiry.IsZero() && tok.ExpiresIn > 0 {
-		tok.Expiry = tok.ObtainedAt.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	if status >= 400 {
+		return fmt.Errorf("token revocation failed: %s", strings.TrimSpace(string(body)))
 	}
-	tok.AuthURL = baseURL
-	tok.Realm = realm
-	tok.ClientID = clientID
-	return &tok, nil
+	return nil
+}
+
+// EndSession ends the user's SSO session at the provider's end-session
+// endpoint, identifying it with the ID token.
+func EndSession(ctx context.Context, baseURL, realm, clientID, idToken string) error {
+	endpoint, err := EndSessionEndpoint(baseURL, realm)
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	form.Set("id_token_hint", idToken)
+	status, body, err := postForm(ctx, endpoint, form)
+	if err != nil {
+		return err
+	}
+	if status >= 400 {
+		return fmt.Errorf("end session failed: %s: %s", http.StatusText(status), strings.TrimSpace(string(body)))
+	}
+	return nil
 }
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	TextFormatText      = "text"
+	TextFormatMarkdown  = "markdown"
+	TextFormatPagesJSON = "pages-json"
+)
+
+var TextFormatOptions = []string{TextFormatText, TextFormatMarkdown, TextFormatPagesJSON}
+
+type OCRPage struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+type TextConvertOptions struct {
+	Format      string
+	PageMarkers bool
+}
+
+var (
+	hyphenBreakRe = regexp.MustCompile(`(\p{L})-[ \t]*\r?\n[ \t]*(\p{Ll})`)
+	spaceRunRe    = regexp.MustCompile(`[ \t\x{00a0}]+`)
+	blankRunRe    = regexp.MustCompile(`\n{3,}`)
+)
+
+// SplitOCRPages splits a raw OCR or content response into pages. JSON
+// responses (an array of strings, an array of page objects, or an object
+// with a "pages" field) are used as-is; plain text is split on form feeds.
+func SplitOCRPages(raw []byte) []OCRPage {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		if pages, ok := parseJSONPages(trimmed); ok {
+			return pages
+		}
+	}
+	text := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	parts := strings.Split(text, "\f")
+	pages := make([]OCRPage, 0, len(parts))
+	for i, p := range parts {
+		if i == len(parts)-1 && strings.TrimSpace(p) == "" && i > 0 {
+			break
+		}
+		pages = append(pages, OCRPage{Page: i + 1, Text: p})
+	}
+	return pages
+}
+
+func parseJSONPages(b []byte) ([]OCRPage, bool) {
+	var wrapper struct {
+		Pages json.RawMessage `json:"pages"`
+		Text  *string         `json:"text"`
+	}
+	if b[0] == '{' {
+		if err := json.Unmarshal(b, &wrapper); err != nil {
+			return nil, false
+		}
+		if len(wrapper.Pages) == 0 {
+			if wrapper.Text != nil {
+				return SplitOCRPages([]byte(*wrapper.Text)), true
+			}
+			return nil, false
+		}
+		b = wrapper.Pages
+	}
+
+	var texts []string
+	if err := json.Unmarshal(b, &texts); err == nil {
+		pages := make([]OCRPage, len(texts))
+		for i, t := range texts {
+			pages[i] = OCRPage{Page: i + 1, Text: t}
+		}
+		return pages, true
+	}
+
+	var objs []struct {
+		Page       int    `json:"page"`
+		PageNumber int    `json:"pageNumber"`
+		Text       string `json:"text"`
+		Content    string `json:"content"`
+	}
+	if err := json.Unmarshal(b, &objs); err != nil {
+		return nil, false
+	}
+	pages := make([]OCRPage, len(objs))
+	for i, o := range objs {
+		n := o.Page
+		if n == 0 {
+			n = o.PageNumber
+		}
+		if n == 0 {
+			n = i + 1
+		}
+		text := o.Text
+		if text == "" {
+			text = o.Content
+		}
+		pages[i] = OCRPage{Page: n, Text: text}
+	}
+	return pages, true
+}
+
+// NormalizeOCRText joins words hyphenated across line breaks, collapses runs
+// of spaces and tabs, strips trailing whitespace and squeezes blank lines.
+func NormalizeOCRText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\u00ad", "")
+	s = hyphenBreakRe.ReplaceAllString(s, "$1$2")
+	s = spaceRunRe.ReplaceAllString(s, " ")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	s = blankRunRe.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// ConvertOCR renders a raw OCR or content response in the requested format.
+func ConvertOCR(raw []byte, opts TextConvertOptions) ([]byte, error) {
+	pages := SplitOCRPages(raw)
+	for i := range pages {
+		pages[i].Text = NormalizeOCRText(pages[i].Text)
+	}
+
+	switch opts.Format {
+	case TextFormatText:
+		var b strings.Builder
+		for i, p := range pages {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			if opts.PageMarkers {
+				fmt.Fprintf(&b, "--- Page %d ---\n\n", p.Page)
+			}
+			b.WriteString(p.Text)
+		}
+		b.WriteString("\n")
+		return []byte(b.String()), nil
+	case TextFormatMarkdown:
+		var b strings.Builder
+		for i, p := range pages {
+			if i > 0 {
+				b.WriteString("\n\n")
+				if !opts.PageMarkers {
+					b.WriteString("---\n\n")
+				}
+			}
+			if opts.PageMarkers {
+				fmt.Fprintf(&b, "## Page %d\n\n", p.Page)
+			}
+			b.WriteString(p.Text)
+		}
+		b.WriteString("\n")
+		return []byte(b.String()), nil
+	case TextFormatPagesJSON:
+		b, err := json.MarshalIndent(pages, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	default:
+		return nil, fmt.Errorf("invalid format %q; valid values: %s", opts.Format, strings.Join(TextFormatOptions, ", "))
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+const (
+	PageSizeA4     = "a4"
+	PageSizeLetter = "letter"
+	PageSizeImage  = "image"
+
+	defaultImageDPI = 150
+)
+
+type ImageSource struct {
+	Name string
+	Data []byte
+}
+
+type ImagePDFOptions struct {
+	PageSize   string
+	DPI        int
+	AutoRotate bool
+}
+
+type pdfImage struct {
+	jpeg          []byte
+	width, height int
+	colorSpace    string
+	pageW, pageH  float64
+	drawW, drawH  float64
+}
+
+// BuildImagePDF assembles the images into a multi-page PDF, one image per
+// page in the given order. JPEGs are embedded as-is unless they need to be
+// rotated or downscaled; other formats are re-encoded as JPEG.
+func BuildImagePDF(images []ImageSource, opts ImagePDFOptions) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to combine")
+	}
+	pages := make([]pdfImage, 0, len(images))
+	for _, src := range images {
+		p, err := prepareImage(src, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.Name, err)
+		}
+		pages = append(pages, p)
+	}
+	return writeImagePDF(pages), nil
+}
+
+func prepareImage(src ImageSource, opts ImagePDFOptions) (pdfImage, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(src.Data))
+	if err != nil {
+		return pdfImage{}, err
+	}
+	orientation := 1
+	if opts.AutoRotate && format == "jpeg" {
+		orientation = JPEGOrientation(src.Data)
+	}
+	w, h := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		w, h = h, w
+	}
+
+	var p pdfImage
+	switch pageSize := strings.ToLower(opts.PageSize); pageSize {
+	case PageSizeImage:
+		dpi := opts.DPI
+		if dpi <= 0 {
+			dpi = defaultImageDPI
+		}
+		p.pageW, p.pageH = float64(w)*72/float64(dpi), float64(h)*72/float64(dpi)
+	case PageSizeA4, PageSizeLetter, "":
+		p.pageW, p.pageH = 595.28, 841.89
+		if pageSize == PageSizeLetter {
+			p.pageW, p.pageH = 612, 792
+		}
+		if (w > h) != (p.pageW > p.pageH) {
+			p.pageW, p.pageH = p.pageH, p.pageW
+		}
+	default:
+		return pdfImage{}, fmt.Errorf("invalid page size %q; valid values: a4, letter, image", opts.PageSize)
+	}
+	scale := min(p.pageW/float64(w), p.pageH/float64(h))
+	p.drawW, p.drawH = float64(w)*scale, float64(h)*scale
+
+	maxW, maxH := w, h
+	if opts.DPI > 0 {
+		maxW, maxH = int(p.drawW/72*float64(opts.DPI)+0.5), int(p.drawH/72*float64(opts.DPI)+0.5)
+	}
+	needsResize := w > maxW || h > maxH
+
+	if format == "jpeg" && orientation == 1 && !needsResize && cfg.ColorModel != color.CMYKModel {
+		p.jpeg = src.Data
+		p.width, p.height = cfg.Width, cfg.Height
+		p.colorSpace = "DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			p.colorSpace = "DeviceGray"
+		}
+		return p, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src.Data))
+	if err != nil {
+		return pdfImage{}, err
+	}
+	img = ApplyOrientation(img, orientation)
+	if needsResize {
+		img = Downscale(img, maxW, maxH)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattenAlpha(img), &jpeg.Options{Quality: 85}); err != nil {
+		return pdfImage{}, err
+	}
+	p.jpeg = buf.Bytes()
+	p.width, p.height = img.Bounds().Dx(), img.Bounds().Dy()
+	p.colorSpace = "DeviceRGB"
+	return p, nil
+}
+
+// flattenAlpha composites transparent images onto white, since JPEG has no
+// alpha channel.
+func flattenAlpha(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			inv := 0xffff - a
+			dst.Set(x, y, color.RGBA64{uint16(r + inv), uint16(g + inv), uint16(bl + inv), 0xffff})
+		}
+	}
+	return dst
+}
+
+func writeImagePDF(pages []pdfImage) []byte {
+	var buf bytes.Buffer
+	offsets := []int{0}
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets)-1, body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects: 1 catalog, 2 page tree, then per page: page, content, image.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 3+i*3)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)), nil)
+	for i, p := range pages {
+		content, img := 4+i*3, 5+i*3
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im%d %d 0 R >> >> /Contents %d 0 R >>",
+			p.pageW, p.pageH, i, img, content), nil)
+		x, y := (p.pageW-p.drawW)/2, (p.pageH-p.drawH)/2
+		cs := []byte(fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q", p.drawW, p.drawH, x, y, i))
+		obj(fmt.Sprintf("<< /Length %d >>", len(cs)), cs)
+		obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			p.width, p.height, p.colorSpace, len(p.jpeg)), p.jpeg)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets))
+	for _, off := range offsets[1:] {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets), xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,433 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+)
+
+// pdfPasswordPad is the padding string of the standard security handler
+// (ISO 32000-1, 7.6.3.3).
+var pdfPasswordPad = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+var (
+	pdfEncryptRefRe = regexp.MustCompile(`/Encrypt\s*(\d+)\s+(\d+)\s+R`)
+	pdfEncryptDirRe = regexp.MustCompile(`/Encrypt\s*<<`)
+	pdfIDRe         = regexp.MustCompile(`/ID\s*\[`)
+)
+
+// pdfNeedsUserPassword reports whether an encrypted PDF needs a password to
+// open, by trying the empty user password against the standard security
+// handler. PDFs with only an owner password (restricting printing or copying)
+// open without one. An error means the encryption could not be checked.
+func pdfNeedsUserPassword(b []byte) (bool, error) {
+	enc, err := pdfEncryptDict(b)
+	if err != nil {
+		return false, err
+	}
+	if filter, _ := enc["Filter"].(pdfName); filter != "Standard" {
+		return false, fmt.Errorf("unsupported security handler %q", filter)
+	}
+	rev := pdfInt(enc["R"], 0)
+	u, _ := enc["U"].([]byte)
+	switch rev {
+	case 2, 3, 4:
+		o, _ := enc["O"].([]byte)
+		if len(o) < 32 || len(u) < 32 {
+			return false, errors.New("malformed encryption dictionary")
+		}
+		id, err := pdfFirstID(b)
+		if err != nil {
+			return false, err
+		}
+		keyLen := 5
+		if rev >= 3 {
+			def := 40
+			if rev == 4 {
+				def = 128
+			}
+			keyLen = pdfInt(enc["Length"], def) / 8
+		}
+		if keyLen < 5 || keyLen > 16 {
+			return false, fmt.Errorf("invalid key length %d", keyLen*8)
+		}
+		encryptMetadata := true
+		if v, ok := enc["EncryptMetadata"].(bool); ok {
+			encryptMetadata = v
+		}
+		key := pdfFileKey(rev, keyLen, o[:32], int32(pdfInt(enc["P"], 0)), id, encryptMetadata)
+		return !pdfCheckUserKey(rev, key, u[:32], id), nil
+	case 5:
+		if len(u) < 48 {
+			return false, errors.New("malformed encryption dictionary")
+		}
+		sum := sha256.Sum256(u[32:40])
+		return !bytes.Equal(sum[:], u[:32]), nil
+	case 6:
+		if len(u) < 48 {
+			return false, errors.New("malformed encryption dictionary")
+		}
+		k, err := pdfHash2B(nil, u[32:40])
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(k, u[:32]), nil
+	}
+	return false, fmt.Errorf("unsupported security handler revision %d", rev)
+}
+
+// pdfFileKey is algorithm 2 with the empty user password.
+func pdfFileKey(rev, keyLen int, o []byte, p int32, id []byte, encryptMetadata bool) []byte {
+	h := md5.New()
+	h.Write(pdfPasswordPad)
+	h.Write(o)
+	binary.Write(h, binary.LittleEndian, p)
+	h.Write(id)
+	if rev >= 4 && !encryptMetadata {
+		h.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	sum := h.Sum(nil)
+	if rev >= 3 {
+		for range 50 {
+			next := md5.Sum(sum[:keyLen])
+			sum = next[:]
+		}
+	}
+	return sum[:keyLen]
+}
+
+// pdfCheckUserKey is algorithms 4 and 5: it compares the U entry with the one
+// computed from key.
+func pdfCheckUserKey(rev int, key, u, id []byte) bool {
+	if rev == 2 {
+		out := make([]byte, 32)
+		c, _ := rc4.NewCipher(key)
+		c.XORKeyStream(out, pdfPasswordPad)
+		return bytes.Equal(out, u)
+	}
+	sum := md5.Sum(append(append([]byte{}, pdfPasswordPad...), id...))
+	out := sum[:]
+	k := make([]byte, len(key))
+	for i := range 20 {
+		for j := range key {
+			k[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(out, out)
+	}
+	return bytes.Equal(out, u[:16])
+}
+
+// pdfHash2B is algorithm 2.B of ISO 32000-2 for the user password (no
+// user key data).
+func pdfHash2B(password, salt []byte) ([]byte, error) {
+	first := sha256.Sum256(append(append([]byte{}, password...), salt...))
+	k := first[:]
+	var e []byte
+	for round := 0; round < 64 || int(e[len(e)-1]) > round-32; round++ {
+		seq := append(append([]byte{}, password...), k...)
+		k1 := bytes.Repeat(seq, 64)
+		block, err := aes.NewCipher(k[:16])
+		if err != nil {
+			return nil, err
+		}
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		// The first 16 bytes as a big-endian number mod 3; 256 = 1 (mod 3),
+		// so the byte sum has the same remainder.
+		sum := 0
+		for _, c := range e[:16] {
+			sum += int(c)
+		}
+		var h hash.Hash
+		switch sum % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		default:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+	}
+	return k[:32], nil
+}
+
+// pdfEncryptDict finds the encryption dictionary of the last trailer, either
+// inline or as an indirect object. It is never inside an object stream.
+func pdfEncryptDict(b []byte) (map[string]any, error) {
+	if locs := pdfEncryptRefRe.FindAllSubmatch(b, -1); len(locs) > 0 {
+		ref := locs[len(locs)-1]
+		objRe := regexp.MustCompile(`(?:^|[^0-9])` + string(ref[1]) + `\s+` + string(ref[2]) + `\s+obj\s*<<`)
+		objs := objRe.FindAllIndex(b, -1)
+		if len(objs) == 0 {
+			return nil, fmt.Errorf("encryption dictionary %s %s R not found", ref[1], ref[2])
+		}
+		return pdfParseDict(b, objs[len(objs)-1][1])
+	}
+	if locs := pdfEncryptDirRe.FindAllIndex(b, -1); len(locs) > 0 {
+		return pdfParseDict(b, locs[len(locs)-1][1])
+	}
+	return nil, errors.New("encryption dictionary not found")
+}
+
+// pdfFirstID returns the first element of the trailer's /ID array.
+func pdfFirstID(b []byte) ([]byte, error) {
+	locs := pdfIDRe.FindAllIndex(b, -1)
+	if len(locs) == 0 {
+		return nil, errors.New("file identifier (/ID) not found")
+	}
+	l := &pdfLexer{b: b, i: locs[len(locs)-1][1]}
+	v, err := l.value()
+	if err != nil {
+		return nil, err
+	}
+	id, ok := v.([]byte)
+	if !ok {
+		return nil, errors.New("malformed file identifier (/ID)")
+	}
+	return id, nil
+}
+
+func pdfParseDict(b []byte, start int) (map[string]any, error) {
+	l := &pdfLexer{b: b, i: start}
+	return l.dict()
+}
+
+func pdfInt(v any, def int) int {
+	if n, ok := v.(float64); ok {
+		return int(n)
+	}
+	return def
+}
+
+// pdfName is a PDF name object without the leading slash.
+type pdfName string
+
+// pdfLexer reads the PDF objects an encryption dictionary is made of:
+// dictionaries, arrays, strings, names, numbers and keywords. Strings are
+// returned as []byte, numbers as float64 and booleans as bool.
+type pdfLexer struct {
+	b []byte
+	i int
+}
+
+var errPDFSyntax = errors.New("malformed encryption dictionary")
+
+func pdfDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func pdfSpace(c byte) bool {
+	return bytes.IndexByte([]byte("\x00\t\n\f\r "), c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.i < len(l.b) {
+		switch c := l.b[l.i]; {
+		case pdfSpace(c):
+			l.i++
+		case c == '%':
+			for l.i < len(l.b) && l.b[l.i] != '\n' && l.b[l.i] != '\r' {
+				l.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// dict reads the entries of a dictionary whose "<<" has been consumed.
+func (l *pdfLexer) dict() (map[string]any, error) {
+	d := map[string]any{}
+	for {
+		l.skipSpace()
+		if l.i+1 < len(l.b) && l.b[l.i] == '>' && l.b[l.i+1] == '>' {
+			l.i += 2
+			return d, nil
+		}
+		key, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errPDFSyntax
+		}
+		val, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		// The values needed here are all direct; an indirect reference
+		// ("n g R") is stored as nil.
+		if _, ok := val.(float64); ok && l.skipRefTail() {
+			val = nil
+		}
+		d[string(name)] = val
+	}
+}
+
+// skipRefTail consumes "g R" after a number when it is there.
+func (l *pdfLexer) skipRefTail() bool {
+	save := l.i
+	l.skipSpace()
+	if _, err := strconv.Atoi(l.token()); err == nil {
+		l.skipSpace()
+		if l.token() == "R" {
+			return true
+		}
+	}
+	l.i = save
+	return false
+}
+
+func (l *pdfLexer) value() (any, error) {
+	l.skipSpace()
+	if l.i >= len(l.b) {
+		return nil, errPDFSyntax
+	}
+	switch c := l.b[l.i]; c {
+	case '(':
+		l.i++
+		return l.literal()
+	case '<':
+		if l.i+1 < len(l.b) && l.b[l.i+1] == '<' {
+			l.i += 2
+			return l.dict()
+		}
+		l.i++
+		end := bytes.IndexByte(l.b[l.i:], '>')
+		if end < 0 {
+			return nil, errPDFSyntax
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if pdfSpace(byte(r)) {
+				return -1
+			}
+			return r
+		}, l.b[l.i:l.i+end])
+		l.i += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		s := make([]byte, len(digits)/2)
+		if _, err := hex.Decode(s, digits); err != nil {
+			return nil, errPDFSyntax
+		}
+		return s, nil
+	case '[':
+		l.i++
+		var arr []any
+		for {
+			l.skipSpace()
+			if l.i < len(l.b) && l.b[l.i] == ']' {
+				l.i++
+				return arr, nil
+			}
+			v, err := l.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	case '/':
+		l.i++
+		return pdfName(l.token()), nil
+	case ')', '>', ']', '{', '}':
+		return nil, errPDFSyntax
+	}
+	tok := l.token()
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseFloat(tok, 64); err == nil {
+		return n, nil
+	}
+	// Keywords such as R are returned as names.
+	return pdfName(tok), nil
+}
+
+func (l *pdfLexer) token() string {
+	start := l.i
+	for l.i < len(l.b) && !pdfSpace(l.b[l.i]) && !pdfDelimiter(l.b[l.i]) {
+		l.i++
+	}
+	return string(l.b[start:l.i])
+}
+
+// literal reads a string whose "(" has been consumed.
+func (l *pdfLexer) literal() ([]byte, error) {
+	var s []byte
+	depth := 1
+	for l.i < len(l.b) {
+		c := l.b[l.i]
+		l.i++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, nil
+			}
+		case '\\':
+			if l.i >= len(l.b) {
+				return nil, errPDFSyntax
+			}
+			e := l.b[l.i]
+			l.i++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.i < len(l.b) && l.b[l.i] == '\n' {
+					l.i++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for k := 0; k < 2 && l.i < len(l.b) && l.b[l.i] >= '0' && l.b[l.i] <= '7'; k++ {
+						n = n*8 + int(l.b[l.i]-'0')
+						l.i++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, errPDFSyntax
+}
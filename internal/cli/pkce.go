@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
@@ -24,8 +25,7 @@ func AuthURL(baseURL, realm, clientID, redirectURI, scope, state, codeChallenge
 	if baseURL == "" || realm == "" || clientID == "" || redirectURI == "" {
 		return "", fmt.Errorf("missing required auth url parameters")
 	}
-	base := strings.TrimRight(baseURL, "/")
-	u, err := url.Parse(base + "/realms/" + realm + "/protocol/openid-connect/auth")
+	u, err := url.Parse(Provider(baseURL, realm).AuthorizationEndpoint)
 	if err != nil {
 		return "", err
 	}
@@ -47,12 +47,69 @@ func AuthURL(baseURL, realm, clientID, redirectURI, scope, state, codeChallenge
 	return u.String(), nil
 }
 
+// AuthorizationError returns the error an IdP reported on the redirect back
+// to the client (the error and error_description query parameters), or nil.
+func AuthorizationError(q url.Values) error {
+	if q.Get("error") == "" {
+		return nil
+	}
+	return oauthError{Code: q.Get("error"), Description: q.Get("error_description")}
+}
+
+// ParseRedirectURL splits the URL the browser was redirected to after login
+// into the authorization code, the state and the redirect URI it was sent to.
+// An error from the IdP in the URL is returned as such.
+func ParseRedirectURL(raw string) (code, state, redirectURI string, err error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid redirect url: %w", err)
+	}
+	q := u.Query()
+	if err := AuthorizationError(q); err != nil {
+		return "", "", "", fmt.Errorf("login failed: %w", err)
+	}
+	code = q.Get("code")
+	if code == "" {
+		return "", "", "", errors.New("redirect url has no code parameter")
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return code, q.Get("state"), u.String(), nil
+}
+
 func TokenEndpoint(baseURL, realm string) (string, error) {
+	return providerEndpoint(baseURL, realm, "token", func(p *ProviderMetadata) string { return p.TokenEndpoint })
+}
+
+// DeviceEndpoint returns the device authorization endpoint (RFC 8628).
+func DeviceEndpoint(baseURL, realm string) (string, error) {
+	return providerEndpoint(baseURL, realm, "device authorization", func(p *ProviderMetadata) string { return p.DeviceAuthorizationEndpoint })
+}
+
+// RevocationEndpoint returns the token revocation endpoint (RFC 7009).
+func RevocationEndpoint(baseURL, realm string) (string, error) {
+	return providerEndpoint(baseURL, realm, "revocation", func(p *ProviderMetadata) string { return p.RevocationEndpoint })
+}
+
+// EndSessionEndpoint returns the RP-initiated logout endpoint.
+func EndSessionEndpoint(baseURL, realm string) (string, error) {
+	return providerEndpoint(baseURL, realm, "end session", func(p *ProviderMetadata) string { return p.EndSessionEndpoint })
+}
+
+// JWKSEndpoint returns the URL of the provider's signing keys.
+func JWKSEndpoint(baseURL, realm string) (string, error) {
+	return providerEndpoint(baseURL, realm, "jwks", func(p *ProviderMetadata) string { return p.JWKSURI })
+}
+
+func providerEndpoint(baseURL, realm, name string, pick func(*ProviderMetadata) string) (string, error) {
 	if baseURL == "" || realm == "" {
-		return "", fmt.Errorf("missing required token endpoint parameters")
+		return "", fmt.Errorf("missing required %s endpoint parameters", name)
+	}
+	p := Provider(baseURL, realm)
+	endpoint := pick(p)
+	if endpoint == "" {
+		return "", fmt.Errorf("%s does not advertise a %s endpoint", p.Issuer, name)
 	}
-	base := strings.TrimRight(baseURL, "/")
-	u, err := url.Parse(base + "/realms/" + realm + "/protocol/openid-connect/token")
+	u, err := url.Parse(endpoint)
 	if err != nil {
 		return "", err
 	}
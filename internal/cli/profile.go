@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	profilesFileName = "profiles.yaml"
+	profilesDirName  = "profiles"
+
+	// DefaultProfile names the settings outside any profile: labrador.yaml,
+	// environment variables and the token files directly in the config
+	// directory.
+	DefaultProfile = "default"
+)
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile is one named account or environment with its own endpoints,
+// credentials and token storage.
+type Profile struct {
+	APIURL   string         `yaml:"api_url,omitempty"`
+	APIToken string         `yaml:"api_token,omitempty"`
+	Keycloak KeycloakConfig `yaml:"keycloak,omitempty"`
+}
+
+type KeycloakConfig struct {
+	URL      string `yaml:"url,omitempty"`
+	Realm    string `yaml:"realm,omitempty"`
+	ClientID string `yaml:"client_id,omitempty"`
+	Issuer   string `yaml:"issuer,omitempty"`
+}
+
+// Profiles is the content of profiles.yaml.
+type Profiles struct {
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// Names returns the profile names in sorted order.
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// activeProfile selects the directory used for tokens, PKCE state and other
+// per-profile state files. Empty means the default profile.
+var activeProfile string
+
+// UseProfile switches token and state storage to the named profile for the
+// rest of the process.
+func UseProfile(name string) {
+	if name == DefaultProfile {
+		name = ""
+	}
+	activeProfile = name
+}
+
+// ActiveProfile returns the profile set by UseProfile, or DefaultProfile.
+func ActiveProfile() string {
+	if activeProfile == "" {
+		return DefaultProfile
+	}
+	return activeProfile
+}
+
+func ValidateProfileName(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("%q is reserved for the settings outside any profile", DefaultProfile)
+	}
+	if !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+func profilesPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, profilesFileName), nil
+}
+
+// ProfileDir returns the directory holding a profile's token and state files.
+func ProfileDir(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name == DefaultProfile {
+		return dir, nil
+	}
+	return filepath.Join(dir, profilesDirName, name), nil
+}
+
+// LoadProfiles reads profiles.yaml; a missing file yields no profiles.
+func LoadProfiles() (*Profiles, error) {
+	p := &Profiles{Profiles: map[string]*Profile{}}
+	path, err := profilesPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if p.Profiles == nil {
+		p.Profiles = map[string]*Profile{}
+	}
+	return p, nil
+}
+
+func SaveProfiles(p *Profiles) error {
+	path, err := profilesPath()
+	if err != nil {
+		return err
+	}
+	if err := ensureDir(path); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RemoveProfileData deletes a profile's token and state directory.
+func RemoveProfileData(name string) error {
+	if name == "" || name == DefaultProfile {
+		return fmt.Errorf("refusing to remove the default profile directory")
+	}
+	dir, err := ProfileDir(name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	mimePDF  = "application/pdf"
+	mimeDOC  = "application/msword"
+	mimeXLS  = "application/vnd.ms-excel"
+	mimePPT  = "application/vnd.ms-powerpoint"
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	mimeOLE2 = "application/x-ole-storage"
+	mimeZIP  = "application/zip"
+	mimeRTF  = "application/rtf"
+	mimeHEIC = "image/heic"
+	mimeTIFF = "image/tiff"
+)
+
+// SupportedUploadTypes lists the content types Labradoc processes.
+var SupportedUploadTypes = map[string]struct{}{
+	mimePDF: {}, mimeDOC: {}, mimeXLS: {}, mimePPT: {}, mimeDOCX: {}, mimeXLSX: {}, mimePPTX: {}, mimeRTF: {},
+	"application/vnd.oasis.opendocument.text":         {},
+	"application/vnd.oasis.opendocument.spreadsheet":  {},
+	"application/vnd.oasis.opendocument.presentation": {},
+	"image/png": {}, "image/jpeg": {}, "image/gif": {}, "image/bmp": {}, "image/webp": {}, mimeTIFF: {}, mimeHEIC: {},
+	"text/html": {}, "text/plain": {},
+}
+
+var (
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	// "EncryptedPackage" in UTF-16LE, the stream a password-protected OOXML
+	// file is wrapped in.
+	encryptedPackageName = []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00e\x00d\x00P\x00a\x00c\x00k\x00a\x00g\x00e\x00")
+)
+
+// SniffContentType determines the content type from magic numbers, using the
+// file extension only to tell apart formats that share a container.
+func SniffContentType(b []byte, name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case bytes.HasPrefix(b, []byte("%PDF-")):
+		return mimePDF
+	case bytes.HasPrefix(b, oleMagic):
+		switch ext {
+		case ".doc":
+			return mimeDOC
+		case ".xls":
+			return mimeXLS
+		case ".ppt":
+			return mimePPT
+		}
+		return mimeOLE2
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return sniffZip(b)
+	case bytes.HasPrefix(b, []byte("{\\rtf")):
+		return mimeRTF
+	case bytes.HasPrefix(b, []byte("II*\x00")), bytes.HasPrefix(b, []byte("MM\x00*")):
+		return mimeTIFF
+	case len(b) >= 12 && string(b[4:8]) == "ftyp" && (string(b[8:12]) == "heic" || string(b[8:12]) == "heix" || string(b[8:12]) == "mif1"):
+		return mimeHEIC
+	}
+	ct := http.DetectContentType(b)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return ct
+}
+
+func sniffZip(b []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return mimeZIP
+	}
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "mimetype":
+			rc, err := f.Open()
+			if err != nil {
+				return mimeZIP
+			}
+			buf := make([]byte, 128)
+			n, _ := rc.Read(buf)
+			rc.Close()
+			return strings.TrimSpace(string(buf[:n]))
+		case strings.HasPrefix(f.Name, "word/"):
+			return mimeDOCX
+		case strings.HasPrefix(f.Name, "xl/"):
+			return mimeXLSX
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return mimePPTX
+		}
+	}
+	return mimeZIP
+}
+
+type UploadCheck struct {
+	ContentType string
+	Size        int64
+	Errors      []string
+	Warnings    []string
+}
+
+// CheckUpload runs the local pre-flight checks for an upload: empty or
+// oversized files, unsupported types, and password-protected or truncated
+// documents.
+// maxSize <= 0 disables the size limit.
+func CheckUpload(b []byte, name string, maxSize int64) UploadCheck {
+	c := UploadCheck{
+		ContentType: SniffContentType(b, name),
+		Size:        int64(len(b)),
+	}
+	if len(b) == 0 {
+		c.Errors = append(c.Errors, "file is empty")
+		return c
+	}
+	if maxSize > 0 && c.Size > maxSize {
+		c.Errors = append(c.Errors, fmt.Sprintf("file is %d bytes, above the %d byte limit", c.Size, maxSize))
+	}
+
+	switch c.ContentType {
+	case mimePDF:
+		tail := b
+		if len(tail) > 2048 {
+			tail = tail[len(tail)-2048:]
+		}
+		if !bytes.Contains(tail, []byte("%%EOF")) {
+			c.Errors = append(c.Errors, "PDF is truncated or corrupt (no %%EOF marker)")
+		}
+		if bytes.Contains(b, []byte("/Encrypt")) {
+			// Only a user password stops the document from being read; an
+			// owner password just restricts printing, copying and the like.
+			needs, err := pdfNeedsUserPassword(b)
+			switch {
+			case err != nil:
+				c.Warnings = append(c.Warnings, fmt.Sprintf("PDF is encrypted and may need a password to open (%v)", err))
+			case needs:
+				c.Errors = append(c.Errors, "PDF is password-protected (needs a password to open)")
+			}
+		}
+	case mimeOLE2:
+		if bytes.Contains(b, encryptedPackageName) {
+			c.Errors = append(c.Errors, "Office document is password-protected")
+		} else {
+			c.Errors = append(c.Errors, "unrecognised OLE2 document; expected .doc, .xls or .ppt")
+		}
+	case mimeZIP:
+		if _, err := zip.NewReader(bytes.NewReader(b), c.Size); err != nil {
+			c.Errors = append(c.Errors, "archive is truncated or corrupt")
+		}
+	}
+
+	if _, ok := SupportedUploadTypes[c.ContentType]; !ok && c.ContentType != mimeOLE2 {
+		c.Errors = append(c.Errors, fmt.Sprintf("unsupported content type %s", c.ContentType))
+	}
+	if declared := mimeByExt(name); declared != "" && declared != c.ContentType && len(c.Errors) == 0 {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("extension suggests %s but content is %s", declared, c.ContentType))
+	}
+	return c
+}
+
+func mimeByExt(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return mimePDF
+	case ".docx":
+		return mimeDOCX
+	case ".xlsx":
+		return mimeXLSX
+	case ".pptx":
+		return mimePPTX
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -27,8 +28,14 @@ type Token struct {
 	Realm            string    `json:"realm,omitempty"`
 	ClientID         string    `json:"client_id,omitempty"`
 	APIURL           string    `json:"api_url,omitempty"`
+	Grant            string    `json:"grant,omitempty"`
+	ClientSecretFile string    `json:"client_secret_file,omitempty"`
 }
 
+// DefaultPKCETTL is how long a pending PKCE session from auth url can be
+// exchanged.
+const DefaultPKCETTL = 15 * time.Minute
+
 type PKCEState struct {
 	CodeVerifier  string    `json:"code_verifier"`
 	CodeChallenge string    `json:"code_challenge"`
@@ -36,6 +43,16 @@ type PKCEState struct {
 	RedirectURI   string    `json:"redirect_uri"`
 	Scope         string    `json:"scope"`
 	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`
+}
+
+func (s *PKCEState) expired(now time.Time) bool {
+	return now.After(s.ExpiresAt)
+}
+
+// pkceFile is the layout of pkce.json: pending sessions keyed by state.
+type pkceFile struct {
+	Sessions map[string]*PKCEState `json:"sessions"`
 }
 
 func configDir() (string, error) {
@@ -46,8 +63,22 @@ func configDir() (string, error) {
 	return filepath.Join(base, "labradoc", "cli"), nil
 }
 
+// StatePath returns the path of a named state file in the active profile's
+// directory, creating the directory if needed.
+func StatePath(name string) (string, error) {
+	dir, err := ProfileDir(activeProfile)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := ensureDir(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
 func tokenPath() (string, error) {
-	dir, err := configDir()
+	dir, err := ProfileDir(activeProfile)
 	if err != nil {
 		return "", err
 	}
@@ -55,7 +86,7 @@ func tokenPath() (string, error) {
 }
 
 func pkcePath() (string, error) {
-	dir, err := configDir()
+	dir, err := ProfileDir(activeProfile)
 	if err != nil {
 		return "", err
 	}
@@ -72,7 +103,38 @@ func LoadToken() (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(path)
+	return loadTokenFile(path)
+}
+
+// HasToken reports whether the active profile has a stored token, without
+// reading or decrypting it.
+func HasToken() bool {
+	path, err := tokenPath()
+	return err == nil && StoredForm(path) != ""
+}
+
+// LoadProfileToken reads the stored token of any profile without switching
+// to it.
+func LoadProfileToken(profile string) (*Token, error) {
+	dir, err := ProfileDir(profile)
+	if err != nil {
+		return nil, err
+	}
+	return loadTokenFile(filepath.Join(dir, tokenFileName))
+}
+
+// ProfileTokenForm reports how a profile's token is stored: "encrypted",
+// "plaintext" or "" when there is none.
+func ProfileTokenForm(profile string) string {
+	dir, err := ProfileDir(profile)
+	if err != nil {
+		return ""
+	}
+	return StoredForm(filepath.Join(dir, tokenFileName))
+}
+
+func loadTokenFile(path string) (*Token, error) {
+	b, err := tokenStore.Read(path)
 	if err != nil {
 		return nil, err
 	}
@@ -91,9 +153,47 @@ func SaveToken(t Token) error {
 	if err != nil {
 		return err
 	}
-	if err := ensureDir(path); err != nil {
+	unlock, err := lockFile(path)
+	if err != nil {
 		return err
 	}
+	defer unlock()
+	return writeToken(path, t)
+}
+
+// UpdateToken renews the stored token under the token file lock. seen is the
+// token the caller found expired or stale; when the stored token has changed
+// since and is still valid, another process already renewed it and the stored
+// token is returned with renewed false instead of calling renew again.
+func UpdateToken(seen *Token, renew func(cur *Token) (*Token, error)) (tok *Token, renewed bool, err error) {
+	path, err := tokenPath()
+	if err != nil {
+		return nil, false, err
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+	cur, err := loadTokenFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if seen != nil && cur.AccessToken != seen.AccessToken && !cur.Expired() {
+		return cur, false, nil
+	}
+	fresh, err := renew(cur)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := writeToken(path, *fresh); err != nil {
+		return nil, false, err
+	}
+	return fresh, true, nil
+}
+
+// writeToken must be called with the token file lock held.
+func writeToken(path string, t Token) error {
 	if t.ObtainedAt.IsZero() {
 		t.ObtainedAt = time.Now().UTC()
 	}
@@ -104,7 +204,7 @@ func SaveToken(t Token) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, b, 0o600)
+	return tokenStore.Write(path, b)
 }
 
 func ClearToken() error {
@@ -112,47 +212,141 @@ func ClearToken() error {
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+	unlock, err := lockFile(path)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer unlock()
+	return tokenStore.Remove(path)
 }
 
-func LoadPKCEState() (*PKCEState, error) {
+// LoadPKCESession returns the pending PKCE session for state. An empty state
+// selects the only pending session; it is an error when there are several.
+func LoadPKCESession(state string) (*PKCEState, error) {
 	path, err := pkcePath()
 	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(path)
-	if err != nil {
+	sessions, err := readPKCESessions(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return nil, err
 	}
-	var s PKCEState
-	if err := json.Unmarshal(b, &s); err != nil {
-		return nil, err
+	now := time.Now()
+	if state == "" {
+		var pending []*PKCEState
+		for _, sess := range sessions {
+			if !sess.expired(now) {
+				pending = append(pending, sess)
+			}
+		}
+		switch len(pending) {
+		case 0:
+			return nil, errors.New("no pending PKCE session; run auth url first")
+		case 1:
+			return pending[0], nil
+		}
+		return nil, fmt.Errorf("%d pending PKCE sessions; pass --state or --redirect-url to pick one", len(pending))
+	}
+	sess, ok := sessions[state]
+	if !ok {
+		return nil, fmt.Errorf("no pending PKCE session for state %q", state)
+	}
+	if sess.expired(now) {
+		return nil, fmt.Errorf("PKCE session for state %q expired at %s; run auth url again", state, sess.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	return sess, nil
+}
+
+// SavePKCESession adds a pending PKCE session keyed by its state and drops
+// expired ones. Without ExpiresAt the session lasts DefaultPKCETTL.
+func SavePKCESession(s PKCEState) error {
+	if s.State == "" {
+		return errors.New("pkce session needs a state")
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now().UTC()
 	}
-	if s.CodeVerifier == "" || s.RedirectURI == "" {
-		return nil, errors.New("pkce state missing required fields")
+	if s.ExpiresAt.IsZero() {
+		s.ExpiresAt = s.CreatedAt.Add(DefaultPKCETTL)
 	}
-	return &s, nil
+	return updatePKCESessions(func(sessions map[string]*PKCEState) {
+		sessions[s.State] = &s
+	})
+}
+
+// RemovePKCESession deletes the session for state, for example after its
+// code was exchanged, and drops expired ones.
+func RemovePKCESession(state string) error {
+	return updatePKCESessions(func(sessions map[string]*PKCEState) {
+		delete(sessions, state)
+	})
 }
 
-func SavePKCEState(s PKCEState) error {
+func updatePKCESessions(change func(map[string]*PKCEState)) error {
 	path, err := pkcePath()
 	if err != nil {
 		return err
 	}
-	if err := ensureDir(path); err != nil {
+	unlock, err := lockFile(path)
+	if err != nil {
 		return err
 	}
-	if s.CreatedAt.IsZero() {
-		s.CreatedAt = time.Now().UTC()
+	defer unlock()
+	sessions, err := readPKCESessions(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if sessions == nil {
+		sessions = map[string]*PKCEState{}
 	}
-	b, err := json.MarshalIndent(s, "", "  ")
+	change(sessions)
+	now := time.Now()
+	for state, sess := range sessions {
+		if sess.expired(now) {
+			delete(sessions, state)
+		}
+	}
+	if len(sessions) == 0 {
+		return tokenStore.Remove(path)
+	}
+	b, err := json.MarshalIndent(pkceFile{Sessions: sessions}, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, b, 0o600)
+	return tokenStore.Write(path, b)
+}
+
+// readPKCESessions reads pkce.json. A file from before sessions were keyed
+// by state holds a single session and is read as such.
+func readPKCESessions(path string) (map[string]*PKCEState, error) {
+	b, err := tokenStore.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	var f pkceFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if f.Sessions == nil {
+		var single PKCEState
+		if err := json.Unmarshal(b, &single); err != nil {
+			return nil, err
+		}
+		f.Sessions = map[string]*PKCEState{}
+		if single.CodeVerifier != "" {
+			f.Sessions[single.State] = &single
+		}
+	}
+	for state, sess := range f.Sessions {
+		if sess == nil || sess.CodeVerifier == "" || sess.RedirectURI == "" {
+			delete(f.Sessions, state)
+			continue
+		}
+		if sess.ExpiresAt.IsZero() {
+			sess.ExpiresAt = sess.CreatedAt.Add(DefaultPKCETTL)
+		}
+	}
+	return f.Sessions, nil
 }
 
 func ClearPKCEState() error {
@@ -160,8 +354,51 @@ func ClearPKCEState() error {
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return tokenStore.Remove(path)
+}
+
+// MigrateProfileFiles rewrites a profile's token and PKCE files with the
+// given store, decrypting or encrypting them as needed. It returns the files
+// that were converted.
+func MigrateProfileFiles(profile string, to TokenStore) ([]string, error) {
+	dir, err := ProfileDir(profile)
+	if err != nil {
+		return nil, err
+	}
+	var converted []string
+	for _, name := range []string{tokenFileName, pkceFileName} {
+		path := filepath.Join(dir, name)
+		form := StoredForm(path)
+		if form == "" || form == to.Kind() {
+			continue
+		}
+		if err := migrateFile(path, form, to); err != nil {
+			return converted, err
+		}
+		converted = append(converted, path)
+	}
+	return converted, nil
+}
+
+func migrateFile(path, form string, to TokenStore) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var b []byte
+	if form == StoreEncrypted {
+		b, err = encryptedStore{}.Read(path)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
 		return err
 	}
-	return nil
+	return to.Write(path, b)
 }
@@ -0,0 +1,407 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Token store backends, selected with token_store in labrador.yaml or
+// TOKEN_STORE.
+const (
+	StorePlaintext = "plaintext"
+	StoreEncrypted = "encrypted"
+)
+
+const (
+	// EnvTokenKey holds a 32-byte AES key, hex or base64 encoded.
+	EnvTokenKey = "LABRADOC_TOKEN_KEY"
+	// EnvTokenPassphrase holds a passphrase the key is derived from.
+	EnvTokenPassphrase = "LABRADOC_TOKEN_PASSPHRASE"
+
+	encryptedSuffix = ".enc"
+	secretPrefix    = "enc:v1:"
+
+	kdfKey    = "key"
+	kdfScrypt = "scrypt"
+
+	// scrypt parameters recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// TokenStore persists the secret files of a profile directory: the OAuth
+// token and the PKCE state. Paths are the plaintext file names; a backend may
+// store them under a different name.
+type TokenStore interface {
+	Kind() string
+	Read(path string) ([]byte, error)
+	Write(path string, data []byte) error
+	// Remove deletes the file in every backend's format.
+	Remove(path string) error
+}
+
+var tokenStore TokenStore = plaintextStore{}
+
+// UseTokenStore selects the backend for the rest of the process. An empty
+// kind picks the encrypted store when a key or passphrase is set in the
+// environment and the plaintext store otherwise.
+func UseTokenStore(kind string) error {
+	store, err := NewTokenStore(kind)
+	if err != nil {
+		return err
+	}
+	tokenStore = store
+	return nil
+}
+
+// ActiveTokenStore returns the backend selected by UseTokenStore.
+func ActiveTokenStore() TokenStore {
+	return tokenStore
+}
+
+func NewTokenStore(kind string) (TokenStore, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "":
+		if os.Getenv(EnvTokenKey) != "" || os.Getenv(EnvTokenPassphrase) != "" {
+			return encryptedStore{}, nil
+		}
+		return plaintextStore{}, nil
+	case StorePlaintext, "file":
+		return plaintextStore{}, nil
+	case StoreEncrypted:
+		return encryptedStore{}, nil
+	}
+	return nil, fmt.Errorf("invalid token_store %q; valid values: %s, %s", kind, StorePlaintext, StoreEncrypted)
+}
+
+type plaintextStore struct{}
+
+func (plaintextStore) Kind() string { return StorePlaintext }
+
+func (plaintextStore) Read(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && fileExists(path+encryptedSuffix) {
+		return nil, fmt.Errorf("%s is encrypted; set token_store: %s and %s or %s", path+encryptedSuffix, StoreEncrypted, EnvTokenKey, EnvTokenPassphrase)
+	}
+	return b, err
+}
+
+func (plaintextStore) Write(path string, data []byte) error {
+	if err := writeFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	return removeIfExists(path + encryptedSuffix)
+}
+
+func (plaintextStore) Remove(path string) error {
+	return removeBoth(path)
+}
+
+// encryptedStore keeps each file as <name>.enc, an AES-256-GCM envelope.
+// Files still in plaintext are read as they are and encrypted on the next
+// write.
+type encryptedStore struct{}
+
+func (encryptedStore) Kind() string { return StoreEncrypted }
+
+func (encryptedStore) Read(path string) ([]byte, error) {
+	b, err := os.ReadFile(path + encryptedSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openEnvelope(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path+encryptedSuffix, err)
+	}
+	return plain, nil
+}
+
+func (encryptedStore) Write(path string, data []byte) error {
+	b, err := sealEnvelope(data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path+encryptedSuffix, b, 0o600); err != nil {
+		return err
+	}
+	return removeIfExists(path)
+}
+
+func (encryptedStore) Remove(path string) error {
+	return removeBoth(path)
+}
+
+// StoredForm reports how a secret file is currently kept on disk: "encrypted",
+// "plaintext" or "" when it does not exist.
+func StoredForm(path string) string {
+	switch {
+	case fileExists(path + encryptedSuffix):
+		return StoreEncrypted
+	case fileExists(path):
+		return StorePlaintext
+	}
+	return ""
+}
+
+// envelope is the on-disk format of encrypted files and secrets.
+type envelope struct {
+	Version    int    `json:"v"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func sealEnvelope(plain []byte) ([]byte, error) {
+	env := envelope{Version: 1}
+	var key []byte
+	if raw, err := envKey(); err != nil {
+		return nil, err
+	} else if raw != nil {
+		env.KDF, key = kdfKey, raw
+	} else {
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		env.KDF, env.Salt = kdfScrypt, make([]byte, 16)
+		if _, err := rand.Read(env.Salt); err != nil {
+			return nil, err
+		}
+		if key, err = scrypt.Key(pass, env.Salt, scryptN, scryptR, scryptP, 32); err != nil {
+			return nil, err
+		}
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, plain, nil)
+	return json.Marshal(env)
+}
+
+func openEnvelope(b []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %w", err)
+	}
+	if env.Version != 1 {
+		return nil, fmt.Errorf("unsupported encryption version %d", env.Version)
+	}
+	var key []byte
+	switch env.KDF {
+	case kdfKey:
+		raw, err := envKey()
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			return nil, fmt.Errorf("encrypted with a key; set %s", EnvTokenKey)
+		}
+		key = raw
+	case kdfScrypt:
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if key, err = scrypt.Key(pass, env.Salt, scryptN, scryptR, scryptP, 32); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", env.KDF)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data: bad nonce")
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("decryption failed (wrong key or passphrase?)")
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// envKey decodes LABRADOC_TOKEN_KEY; nil means it is not set.
+func envKey() ([]byte, error) {
+	v := strings.TrimSpace(os.Getenv(EnvTokenKey))
+	if v == "" {
+		return nil, nil
+	}
+	if b, err := hex.DecodeString(v); err == nil && len(b) == 32 {
+		return b, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(v); err == nil && len(b) == 32 {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be 32 bytes, hex or base64 encoded", EnvTokenKey)
+}
+
+var (
+	passphraseOnce  sync.Once
+	passphraseValue []byte
+	passphraseErr   error
+)
+
+// passphrase returns LABRADOC_TOKEN_PASSPHRASE or asks for it once on the
+// terminal.
+func passphrase() ([]byte, error) {
+	passphraseOnce.Do(func() {
+		if v := os.Getenv(EnvTokenPassphrase); v != "" {
+			passphraseValue = []byte(v)
+			return
+		}
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			passphraseErr = fmt.Errorf("token store is encrypted; set %s or %s", EnvTokenKey, EnvTokenPassphrase)
+			return
+		}
+		fmt.Fprint(os.Stderr, "Token store passphrase: ")
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			passphraseErr = err
+			return
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			passphraseErr = errors.New("empty passphrase")
+			return
+		}
+		passphraseValue = b
+	})
+	return passphraseValue, passphraseErr
+}
+
+// PromptNewPassphrase asks for a new passphrase twice on the terminal and
+// uses it for the rest of the process, unless a key or passphrase is already
+// set in the environment.
+func PromptNewPassphrase() error {
+	if os.Getenv(EnvTokenKey) != "" || os.Getenv(EnvTokenPassphrase) != "" {
+		return nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("set %s or %s to encrypt without a terminal", EnvTokenKey, EnvTokenPassphrase)
+	}
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return b, err
+	}
+	first, err := read("New token store passphrase: ")
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(first)) == 0 {
+		return errors.New("empty passphrase")
+	}
+	second, err := read("Repeat passphrase: ")
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(first, second) {
+		return errors.New("passphrases do not match")
+	}
+	passphraseOnce.Do(func() {})
+	passphraseValue, passphraseErr = first, nil
+	return nil
+}
+
+// KeySource describes where the encrypted store gets its key from.
+func KeySource() string {
+	switch {
+	case os.Getenv(EnvTokenKey) != "":
+		return EnvTokenKey
+	case os.Getenv(EnvTokenPassphrase) != "":
+		return EnvTokenPassphrase + " (scrypt)"
+	}
+	return "passphrase prompt (scrypt)"
+}
+
+// IsSealedSecret reports whether a config value was written by SealSecret.
+func IsSealedSecret(s string) bool {
+	return strings.HasPrefix(s, secretPrefix)
+}
+
+// SealSecret encrypts a config value such as an API key for labrador.yaml or
+// profiles.yaml. Sealed values start with "enc:v1:".
+func SealSecret(s string) (string, error) {
+	if s == "" || IsSealedSecret(s) {
+		return s, nil
+	}
+	b, err := sealEnvelope([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// OpenSecret decrypts a value written by SealSecret and returns any other
+// value unchanged.
+func OpenSecret(s string) (string, error) {
+	if !IsSealedSecret(s) {
+		return s, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, secretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	plain, err := openEnvelope(b)
+	if err != nil {
+		return "", fmt.Errorf("encrypted secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func removeBoth(path string) error {
+	if err := removeIfExists(path); err != nil {
+		return err
+	}
+	return removeIfExists(path + encryptedSuffix)
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileName returns the profile selected by --profile, LABRADOC_PROFILE or
+// the current profile in profiles.yaml, in that order.
+func ProfileName(profiles *cli.Profiles) string {
+	if name := strings.TrimSpace(viper.GetString("profile")); name != "" {
+		return name
+	}
+	if profiles.Current != "" {
+		return profiles.Current
+	}
+	return cli.DefaultProfile
+}
+
+// ApplyProfile activates the selected profile: its token storage is used
+// from now on and its settings take precedence over labrador.yaml. Settings
+// given as environment variables still win.
+func ApplyProfile() (string, error) {
+	profiles, err := cli.LoadProfiles()
+	if err != nil {
+		return "", err
+	}
+	name := ProfileName(profiles)
+	if name == cli.DefaultProfile {
+		cli.UseProfile("")
+		return name, nil
+	}
+	p, ok := profiles.Profiles[name]
+	if !ok {
+		return "", fmt.Errorf("profile %q not found (see labradoc profile list)", name)
+	}
+	cli.UseProfile(name)
+	for key, value := range map[string]string{
+		"api_url":            p.APIURL,
+		"api_token":          p.APIToken,
+		"keycloak.url":       p.Keycloak.URL,
+		"keycloak.realm":     p.Keycloak.Realm,
+		"keycloak.client_id": p.Keycloak.ClientID,
+		"keycloak.issuer":    p.Keycloak.Issuer,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, ok := os.LookupEnv(strings.ToUpper(strings.ReplaceAll(key, ".", "_"))); ok {
+			continue
+		}
+		viper.Set(key, value)
+	}
+	return name, nil
+}
+
+// ApplyOIDC passes the configured OIDC issuer and discovery cache lifetime
+// to the auth client. Call it after ApplyProfile so profile values count.
+func ApplyOIDC() {
+	cli.UseIssuer(viper.GetString("keycloak.issuer"), viper.GetDuration("keycloak.discovery_ttl"))
+}
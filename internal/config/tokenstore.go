@@ -0,0 +1,14 @@
+package config
+
+import (
+	"github.com/zamedic/labradoc-cli/internal/cli"
+
+	"github.com/spf13/viper"
+)
+
+// ApplyTokenStore selects the token store backend from token_store in
+// labrador.yaml or TOKEN_STORE; unset picks the encrypted store when
+// LABRADOC_TOKEN_KEY or LABRADOC_TOKEN_PASSPHRASE is set.
+func ApplyTokenStore() error {
+	return cli.UseTokenStore(viper.GetString("token_store"))
+}
@@ -29,6 +29,7 @@ func InitConfig() {
 	// Environment variables override everything
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.BindEnv("profile", "LABRADOC_PROFILE")
 
 	if viper.GetBool("log.debug") {
 		config := zap.NewDevelopmentConfig()